@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// PLMN / TAC AMF-a w anotacjach:
+//
+//	5g.kkarczmarek.dev/plmn: "208-93,001-01"   – MCC-MNC, lista
+//	5g.kkarczmarek.dev/tac:  "000001,00000a"   – 24-bitowe TAC (6 cyfr hex)
+//
+// Wszystkie AMF-y w namespace (anotacje Podów/workloadów i plmnSupportList
+// z amfcfg.yaml) powinny obsługiwać ten sam zbiór PLMN – inaczej UE przełączane
+// między AMF-ami z jednego AMF Set dostaje odmowę rejestracji. Odrzucamy
+// zbiory rozłączne/częściowo sprzeczne; nadzbiór i podzbiór to krok migracji.
+
+const (
+	plmnAnnotation = "5g.kkarczmarek.dev/plmn"
+	tacAnnotation  = "5g.kkarczmarek.dev/tac"
+
+	amfConfigKey = "amfcfg.yaml"
+)
+
+var (
+	// AMF_PLMN_CONSISTENCY=false – bez porównywania PLMN między AMF-ami
+	amfPlmnConsistency = getEnvBool("AMF_PLMN_CONSISTENCY", true)
+)
+
+func splitList(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+}
+
+// parsePlmnList – "MCC-MNC, ..." -> posortowane, unikalne klucze MCC-MNC
+func parsePlmnList(raw string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range splitList(raw) {
+		mcc, mnc, ok := strings.Cut(e, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid PLMN %q (expected MCC-MNC, e.g. 208-93)", e)
+		}
+		if !mccRegex.MatchString(mcc) {
+			return nil, fmt.Errorf("invalid PLMN %q: MCC must be exactly 3 digits", e)
+		}
+		if !mncRegex.MatchString(mnc) {
+			return nil, fmt.Errorf("invalid PLMN %q: MNC must be 2 or 3 digits", e)
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty PLMN list")
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func parseTacList(raw string) ([]string, error) {
+	var out []string
+	for _, e := range splitList(raw) {
+		if !tacRegex.MatchString(e) {
+			return nil, fmt.Errorf("invalid TAC %q (expected 6 hex digits, 24 bits)", e)
+		}
+		out = append(out, strings.ToLower(e))
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty TAC list")
+	}
+	return out, nil
+}
+
+// validatePlmnAnnotations – format anotacji plmn/tac
+func validatePlmnAnnotations(t *podTarget) field.ErrorList {
+	var errs field.ErrorList
+	fp := t.field("metadata", "annotations")
+	if v, ok := t.Meta.Annotations[plmnAnnotation]; ok {
+		if _, err := parsePlmnList(v); err != nil {
+			errs = append(errs, field.Invalid(fp.Key(plmnAnnotation), v, err.Error()))
+		}
+	}
+	if v, ok := t.Meta.Annotations[tacAnnotation]; ok {
+		if _, err := parseTacList(v); err != nil {
+			errs = append(errs, field.Invalid(fp.Key(tacAnnotation), v, err.Error()))
+		}
+	}
+	return errs
+}
+
+// amfPlmns – źródło zbioru PLMN jednego AMF-a (workload albo ConfigMapa)
+type amfPlmns struct {
+	Name  string // deployment/x, pod/x, configmap/x
+	PLMNs []string
+}
+
+// amfConfigPlmns – plmnSupportList z amfcfg.yaml (posortowane, unikalne)
+func amfConfigPlmns(root cfgNode) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range root.get("configuration").get("plmnSupportList").items() {
+		plmn := p.get("plmnId")
+		if !mccRegex.MatchString(plmn.get("mcc").value()) || !mncRegex.MatchString(plmn.get("mnc").value()) {
+			continue
+		}
+		if key := plmn.plmnKey(); !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// namespaceAmfPlmns – AMF-y z anotacją plmn (workloady i Pody bez właściciela –
+// Pody workloadów mają ten sam template) oraz ConfigMapy z amfcfg.yaml (z cache)
+func namespaceAmfPlmns(ns string) ([]amfPlmns, error) {
+	if podLister == nil {
+		return nil, nil
+	}
+	var out []amfPlmns
+	add := func(name string, meta *metav1.ObjectMeta) {
+		if meta.Labels[nfLabelKey] != "amf" {
+			return
+		}
+		if plmns, err := parsePlmnList(meta.Annotations[plmnAnnotation]); err == nil {
+			out = append(out, amfPlmns{Name: name, PLMNs: plmns})
+		}
+	}
+
+	pods, err := podLister.Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	for _, p := range pods {
+		if len(p.OwnerReferences) == 0 {
+			add("pod/"+p.Name, &p.ObjectMeta)
+		}
+	}
+	deps, err := deploymentLister.Deployments(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for _, d := range deps {
+		add("deployment/"+d.Name, &d.Spec.Template.ObjectMeta)
+	}
+	sts, err := statefulSetLister.StatefulSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for _, st := range sts {
+		add("statefulset/"+st.Name, &st.Spec.Template.ObjectMeta)
+	}
+
+	cms, err := configMapLister.ConfigMaps(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	for _, cm := range cms {
+		data, ok := cm.Data[amfConfigKey]
+		if !ok {
+			continue
+		}
+		if root, ferr := parseNFConfig(amfConfigKey, data); ferr == nil {
+			if plmns := amfConfigPlmns(root); len(plmns) > 0 {
+				out = append(out, amfPlmns{Name: "configmap/" + cm.Name, PLMNs: plmns})
+			}
+		}
+	}
+	return out, nil
+}
+
+// plmnSubset – czy każdy PLMN z a jest w b
+func plmnSubset(a, b []string) bool {
+	in := map[string]bool{}
+	for _, p := range b {
+		in[p] = true
+	}
+	for _, p := range a {
+		if !in[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// plmnConflicts – AMF-y (poza self), których zbiór PLMN nie jest ani
+// nadzbiorem, ani podzbiorem own. Nadzbiór/podzbiór przepuszczamy, bo tak
+// wygląda stan przejściowy przy dodawaniu/usuwaniu PLMN (jeden AMF albo
+// ConfigMapa jest zawsze aktualizowany pierwszy).
+func plmnConflicts(self string, own []string, others []amfPlmns) []string {
+	var out []string
+	for _, o := range others {
+		if o.Name == self || plmnSubset(own, o.PLMNs) || plmnSubset(o.PLMNs, own) {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s obsługuje [%s]", o.Name, strings.Join(o.PLMNs, ",")))
+	}
+	return out
+}
+
+// validateAmfPlmnConsistency – anotacja plmn AMF-a względem pozostałych
+// AMF-ów; UPDATE bez zmiany anotacji plmn (i labela nf) nie jest sprawdzany
+func validateAmfPlmnConsistency(t, old *podTarget, namespace string) field.ErrorList {
+	if !amfPlmnConsistency || t.Meta.Labels[nfLabelKey] != "amf" {
+		return nil
+	}
+	raw := t.Meta.Annotations[plmnAnnotation]
+	if old != nil && old.Meta.Labels[nfLabelKey] == "amf" && old.Meta.Annotations[plmnAnnotation] == raw {
+		return nil
+	}
+	own, err := parsePlmnList(raw)
+	if err != nil {
+		// brak anotacji albo zły format (to zgłasza validatePlmnAnnotations)
+		return nil
+	}
+	fp := t.field("metadata", "annotations").Key(plmnAnnotation)
+
+	others, err := namespaceAmfPlmns(namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(fp, err)}
+	}
+	self := strings.ToLower(t.Kind) + "/" + t.Owner.Name
+	if c := plmnConflicts(self, own, others); len(c) > 0 {
+		return field.ErrorList{field.Invalid(fp, raw,
+			fmt.Sprintf("zbiór PLMN [%s] nie jest ani nadzbiorem, ani podzbiorem PLMN innych AMF-ów w namespace: %s",
+				strings.Join(own, ","), strings.Join(c, "; ")))}
+	}
+	return nil
+}
+
+// validateAmfConfigPlmnConsistency – plmnSupportList z amfcfg.yaml względem
+// pozostałych AMF-ów w namespace
+func validateAmfConfigPlmnConsistency(cm *corev1.ConfigMap, namespace string) field.ErrorList {
+	data, ok := cm.Data[amfConfigKey]
+	if !ok || !amfPlmnConsistency {
+		return nil
+	}
+	root, ferr := parseNFConfig(amfConfigKey, data)
+	if ferr != nil {
+		return nil
+	}
+	own := amfConfigPlmns(root)
+	if len(own) == 0 {
+		return nil
+	}
+	list := root.get("configuration").get("plmnSupportList")
+
+	others, err := namespaceAmfPlmns(namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(list.fp, err)}
+	}
+	if c := plmnConflicts("configmap/"+cm.Name, own, others); len(c) > 0 {
+		return field.ErrorList{field.Invalid(list.fp, strings.Join(own, ","),
+			list.msg("zbiór PLMN nie jest ani nadzbiorem, ani podzbiorem PLMN innych AMF-ów w namespace: %s", strings.Join(c, "; ")))}
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Audyt w tle: reguły działają tylko przy admission, więc obiekty sprzed
+// wdrożenia webhooka (albo wpuszczone przez failurePolicy: Ignore) nigdy nie
+// były sprawdzone. Co AUDIT_INTERVAL skanujemy Pody (bez właściciela),
+// workloady, Service'y i ConfigMapy w namespace'ach z admission włączonym,
+// przepuszczamy je przez te same walidatory co /validate i publikujemy
+// wynik jako metryki (/metrics) i raport per namespace (ConfigMapa
+// admission-audit-report w układzie PolicyReport; z CRD wgpolicyk8s.io
+// także prawdziwe PolicyReporty – policyreport.go).
+
+var (
+	// AUDIT_ENABLED=true – okresowy skan istniejących obiektów
+	auditEnabled  = getEnvBool("AUDIT_ENABLED", false)
+	auditInterval = getEnvDuration("AUDIT_INTERVAL", 10*time.Minute)
+	// AUDIT_MAX_RESULTS – limit wpisów w raporcie (ConfigMapa ma limit 1 MiB)
+	auditMaxResults = getEnvInt("AUDIT_MAX_RESULTS", 500)
+
+	auditReportName = "admission-audit-report"
+	auditReportKey  = "report.json"
+)
+
+// auditResult – jedno naruszenie (pole + komunikat walidatora)
+type auditResult struct {
+	Resource corev1.ObjectReference `json:"resource"`
+	Rule     string                 `json:"rule"`
+	Severity string                 `json:"severity,omitempty"`
+	Field    string                 `json:"field"`
+	Type     string                 `json:"type"`
+	Message  string                 `json:"message"`
+}
+
+// auditReport – wynik skanu namespace'u (pass/fail liczone per obiekt)
+type auditReport struct {
+	Namespace string    `json:"namespace"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   struct {
+		Pass  int `json:"pass"`
+		Fail  int `json:"fail"`
+		Error int `json:"error"`
+	} `json:"summary"`
+	Results   []auditResult `json:"results"`
+	Truncated bool          `json:"truncated,omitempty"`
+
+	// scanned / failed per kind – do metryk
+	scanned map[string]int
+	failed  map[string]int
+	// obiekty bez naruszeń – wyniki pass w PolicyReport
+	passed []corev1.ObjectReference
+}
+
+// auditObject – obiekt do przepuszczenia przez walidator /validate
+type auditObject struct {
+	Ref corev1.ObjectReference
+	Raw []byte
+}
+
+func newAuditObject(obj runtime.Object, meta *metav1.ObjectMeta, apiVersion, kind string) (auditObject, error) {
+	// obiekty z List nie mają TypeMeta, a deserializer go wymaga
+	obj.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, kind))
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return auditObject{}, err
+	}
+	return auditObject{
+		Ref: corev1.ObjectReference{
+			APIVersion:      apiVersion,
+			Kind:            kind,
+			Namespace:       meta.Namespace,
+			Name:            meta.Name,
+			UID:             meta.UID,
+			ResourceVersion: meta.ResourceVersion,
+		},
+		Raw: raw,
+	}, nil
+}
+
+// listAuditObjects – Pody bez właściciela, workloady (bez Jobów z CronJoba),
+// Service'y i ConfigMapy namespace'u z cache informerów (kopie – newAuditObject
+// ustawia TypeMeta)
+func listAuditObjects(ns string) ([]auditObject, error) {
+	var out []auditObject
+	add := func(obj runtime.Object, meta *metav1.ObjectMeta, apiVersion, kind string) error {
+		o, err := newAuditObject(obj, meta, apiVersion, kind)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", kind, meta.Name, err)
+		}
+		out = append(out, o)
+		return nil
+	}
+	all := labels.Everything()
+
+	pods, err := podLister.Pods(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	for _, p := range sortedByName(pods) {
+		if len(p.OwnerReferences) == 0 {
+			p = p.DeepCopy()
+			if err := add(p, &p.ObjectMeta, "v1", "Pod"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	deps, err := deploymentLister.Deployments(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for _, d := range sortedByName(deps) {
+		d = d.DeepCopy()
+		if err := add(d, &d.ObjectMeta, "apps/v1", "Deployment"); err != nil {
+			return nil, err
+		}
+	}
+	sts, err := statefulSetLister.StatefulSets(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for _, st := range sortedByName(sts) {
+		st = st.DeepCopy()
+		if err := add(st, &st.ObjectMeta, "apps/v1", "StatefulSet"); err != nil {
+			return nil, err
+		}
+	}
+	dss, err := daemonSetLister.DaemonSets(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list daemonsets: %w", err)
+	}
+	for _, ds := range sortedByName(dss) {
+		ds = ds.DeepCopy()
+		if err := add(ds, &ds.ObjectMeta, "apps/v1", "DaemonSet"); err != nil {
+			return nil, err
+		}
+	}
+	jobs, err := jobLister.Jobs(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	for _, j := range sortedByName(jobs) {
+		if len(j.OwnerReferences) == 0 {
+			j = j.DeepCopy()
+			if err := add(j, &j.ObjectMeta, "batch/v1", "Job"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	cjs, err := cronJobLister.CronJobs(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list cronjobs: %w", err)
+	}
+	for _, cj := range sortedByName(cjs) {
+		cj = cj.DeepCopy()
+		if err := add(cj, &cj.ObjectMeta, "batch/v1", "CronJob"); err != nil {
+			return nil, err
+		}
+	}
+	svcs, err := serviceLister.Services(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	for _, svc := range sortedByName(svcs) {
+		svc = svc.DeepCopy()
+		if err := add(svc, &svc.ObjectMeta, "v1", "Service"); err != nil {
+			return nil, err
+		}
+	}
+	cms, err := configMapLister.ConfigMaps(ns).List(all)
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	for _, cm := range sortedByName(cms) {
+		if cm.Name != auditReportName {
+			cm = cm.DeepCopy()
+			if err := add(cm, &cm.ObjectMeta, "v1", "ConfigMap"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// validateAuditObject – ta sama ścieżka co handleValidate (CREATE, bez old)
+func validateAuditObject(o auditObject, clientset kubernetes.Interface) field.ErrorList {
+	switch {
+	case isPodTargetKind(o.Ref.Kind):
+		return validatePodTarget(o.Raw, nil, o.Ref.Namespace, o.Ref.Kind, clientset)
+	case o.Ref.Kind == "Service":
+		return validateService(o.Raw, nil, o.Ref.Namespace, clientset)
+	case o.Ref.Kind == "ConfigMap":
+		// ostrzeżenia z admission są w audycie naruszeniami
+		errs, warns := validateConfigMap(o.Raw, o.Ref.Namespace, clientset)
+		return append(errs, warns...)
+	}
+	return nil
+}
+
+// auditNamespace – skan jednego namespace'u; obiekty, namespace i obiekty,
+// z którymi walidatory je porównują, pochodzą z cache informerów
+func auditNamespace(clientset kubernetes.Interface, ns string) (*auditReport, error) {
+	objs, err := listAuditObjects(ns)
+	if err != nil {
+		return nil, err
+	}
+	rep := &auditReport{
+		Namespace: ns,
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+		scanned:   map[string]int{},
+		failed:    map[string]int{},
+	}
+	for _, o := range objs {
+		rep.scanned[o.Ref.Kind]++
+		errs := validateAuditObject(o, clientset)
+		if len(errs) == 0 {
+			rep.Summary.Pass++
+			rep.passed = append(rep.passed, o.Ref)
+			continue
+		}
+		internal := true
+		for _, e := range errs {
+			if e.Type != field.ErrorTypeInternal {
+				internal = false
+			}
+			if len(rep.Results) >= auditMaxResults {
+				rep.Truncated = true
+				continue
+			}
+			rep.Results = append(rep.Results, auditResult{
+				Resource: o.Ref,
+				Rule:     auditRuleID(e.Field),
+				Severity: auditSeverity(e.Type),
+				Field:    e.Field,
+				Type:     string(e.Type),
+				Message:  e.Error(),
+			})
+		}
+		// same błędy wewnętrzne (API, rejestr) to nie naruszenie reguły
+		if internal {
+			rep.Summary.Error++
+		} else {
+			rep.Summary.Fail++
+			rep.failed[o.Ref.Kind]++
+			recordAuditViolation(o.Ref, errs)
+		}
+	}
+	return rep, nil
+}
+
+// writeAuditConfigMap – raport jako ConfigMapa admission-audit-report
+func writeAuditConfigMap(ctx context.Context, clientset kubernetes.Interface, rep *auditReport) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	want := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      auditReportName,
+			Namespace: rep.Namespace,
+			Labels: map[string]string{
+				managedByLabelKey: managedByLabelValue,
+				componentLabelKey: "audit",
+			},
+		},
+		Data: map[string]string{auditReportKey: string(data)},
+	}
+	api := clientset.CoreV1().ConfigMaps(rep.Namespace)
+	cur, err := api.Get(ctx, auditReportName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = api.Create(ctx, want, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// bez zmian poza znacznikiem czasu – bez zapisu
+	if equality.Semantic.DeepEqual(cur.Labels, want.Labels) && sameAuditReport(cur.Data[auditReportKey], rep, data) {
+		return nil
+	}
+	cur.Labels, cur.Data = want.Labels, want.Data
+	_, err = api.Update(ctx, cur, metav1.UpdateOptions{})
+	return err
+}
+
+// sameAuditReport – zapisany raport różni się od nowego (data) najwyżej timestampem
+func sameAuditReport(stored string, rep *auditReport, data []byte) bool {
+	var old auditReport
+	if err := json.Unmarshal([]byte(stored), &old); err != nil {
+		return false
+	}
+	old.Timestamp = rep.Timestamp
+	raw, err := json.MarshalIndent(&old, "", "  ")
+	return err == nil && string(raw) == string(data)
+}
+
+// --------- PĘTLA AUDYTU ---------
+
+type auditor struct {
+	clientset kubernetes.Interface
+	metrics   *auditMetrics
+	// reports – PolicyReporty (nil, gdy brak CRD wgpolicyk8s.io)
+	reports *policyReportWriter
+}
+
+func (a *auditor) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(auditInterval)
+	defer ticker.Stop()
+	for {
+		a.scan()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan – jeden przebieg po wszystkich namespace'ach z admission włączonym
+func (a *auditor) scan() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), auditInterval)
+	defer cancel()
+
+	nss, err := namespaceLister.List(labels.SelectorFromSet(labels.Set{admissionLabelKey: "true"}))
+	if err != nil {
+		log.Printf("audit: list namespaces: %v", err)
+		a.metrics.runFailed()
+		return
+	}
+
+	var reports []*auditReport
+	scanErrors := map[string]error{}
+	for _, ns := range sortedByName(nss) {
+		rep, err := auditNamespace(a.clientset, ns.Name)
+		if err != nil {
+			log.Printf("audit %s: %v", ns.Name, err)
+			scanErrors[ns.Name] = err
+			continue
+		}
+		if err := writeAuditConfigMap(ctx, a.clientset, rep); err != nil {
+			log.Printf("audit %s: write report: %v", ns.Name, err)
+		}
+		if rep.Summary.Fail > 0 {
+			log.Printf("audit %s: %d/%d objects violate policies", ns.Name, rep.Summary.Fail, rep.Summary.Pass+rep.Summary.Fail)
+		}
+		reports = append(reports, rep)
+	}
+	if a.reports != nil {
+		if err := a.reports.write(ctx, reports, scanErrors); err != nil {
+			log.Printf("audit: policy reports: %v", err)
+		}
+	}
+	a.metrics.set(reports, start, time.Since(start))
+}
+
+// --------- METRYKI ---------
+
+// auditMetrics – gauge'e ostatniego skanu w formacie tekstowym Prometheusa
+// (bez zależności od client_golang)
+type auditMetrics struct {
+	mu       sync.Mutex
+	scanned  map[[2]string]int // {namespace, kind}
+	failed   map[[2]string]int
+	results  map[string]int // namespace -> liczba naruszeń (pól)
+	lastRun  time.Time
+	duration time.Duration
+	failures int
+}
+
+func newAuditMetrics() *auditMetrics {
+	return &auditMetrics{scanned: map[[2]string]int{}, failed: map[[2]string]int{}, results: map[string]int{}}
+}
+
+func (m *auditMetrics) set(reports []*auditReport, start time.Time, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scanned, m.failed, m.results = map[[2]string]int{}, map[[2]string]int{}, map[string]int{}
+	for _, r := range reports {
+		for k, v := range r.scanned {
+			m.scanned[[2]string{r.Namespace, k}] = v
+		}
+		for k, v := range r.failed {
+			m.failed[[2]string{r.Namespace, k}] = v
+		}
+		m.results[r.Namespace] = len(r.Results)
+	}
+	m.lastRun, m.duration = start, d
+}
+
+func (m *auditMetrics) runFailed() {
+	m.mu.Lock()
+	m.failures++
+	m.mu.Unlock()
+}
+
+func (m *auditMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	gauge := func(name, help string, vals map[[2]string]int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		keys := make([][2]string, 0, len(vals))
+		for k := range vals {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return strings.Join(keys[i][:], "/") < strings.Join(keys[j][:], "/") })
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s{namespace=%q,kind=%q} %d\n", name, k[0], k[1], vals[k])
+		}
+	}
+	gauge("admission_audit_objects_scanned", "Objects checked in the last audit run.", m.scanned)
+	gauge("admission_audit_objects_failed", "Objects violating at least one policy in the last audit run.", m.failed)
+
+	fmt.Fprintf(w, "# HELP admission_audit_violations Field-level violations in the last audit run.\n# TYPE admission_audit_violations gauge\n")
+	nss := make([]string, 0, len(m.results))
+	for ns := range m.results {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+	for _, ns := range nss {
+		fmt.Fprintf(w, "admission_audit_violations{namespace=%q} %d\n", ns, m.results[ns])
+	}
+
+	fmt.Fprintf(w, "# HELP admission_audit_last_run_timestamp_seconds Start of the last audit run.\n# TYPE admission_audit_last_run_timestamp_seconds gauge\n")
+	if !m.lastRun.IsZero() {
+		fmt.Fprintf(w, "admission_audit_last_run_timestamp_seconds %d\n", m.lastRun.Unix())
+	}
+	fmt.Fprintf(w, "# HELP admission_audit_duration_seconds Duration of the last audit run.\n# TYPE admission_audit_duration_seconds gauge\n")
+	fmt.Fprintf(w, "admission_audit_duration_seconds %.3f\n", m.duration.Seconds())
+	fmt.Fprintf(w, "# HELP admission_audit_run_failures_total Audit runs that could not list namespaces.\n# TYPE admission_audit_run_failures_total counter\n")
+	fmt.Fprintf(w, "admission_audit_run_failures_total %d\n", m.failures)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// COSIGN_VERIFY – weryfikacja podpisów obrazów w samym webhooku (zamiast Kyverno)
+	cosignVerify     = getEnvBool("COSIGN_VERIFY", false)
+	cosignPolicyFile = getEnv("COSIGN_POLICY_FILE", "/etc/cosign/policy.yaml")
+	cosignNamespaces = getEnv("COSIGN_NAMESPACES", "free5gc")
+	cosignCacheTTL   = getEnvDuration("COSIGN_CACHE_TTL", 30*time.Minute)
+
+	// ustawiany w main(), nil = weryfikacja wyłączona
+	imageVerifier *cosignVerifier
+)
+
+const (
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignSimpleSigningType   = "application/vnd.dev.cosign.simplesigning.v1+json"
+)
+
+// cosignPolicy – plik COSIGN_POLICY_FILE (np. z ConfigMapy):
+//
+//	registries:
+//	  - prefix: ghcr.io/kkarczmarek/
+//	    keys:
+//	      - |
+//	        -----BEGIN PUBLIC KEY-----
+//	        ...
+type cosignPolicy struct {
+	Registries []cosignRegistryPolicy `json:"registries"`
+}
+
+type cosignRegistryPolicy struct {
+	Prefix string   `json:"prefix"`
+	Keys   []string `json:"keys"`
+
+	publicKeys []crypto.PublicKey
+}
+
+func loadCosignPolicy(path string) (*cosignPolicy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cosign policy: %w", err)
+	}
+	p := &cosignPolicy{}
+	if err := yaml.UnmarshalStrict(raw, p); err != nil {
+		return nil, fmt.Errorf("parse cosign policy %s: %w", path, err)
+	}
+
+	for i := range p.Registries {
+		r := &p.Registries[i]
+		if strings.TrimSpace(r.Prefix) == "" {
+			return nil, fmt.Errorf("cosign policy: registries[%d].prefix is empty", i)
+		}
+		r.Prefix = normalizeImagePrefix(r.Prefix)
+		if len(r.Keys) == 0 {
+			return nil, fmt.Errorf("cosign policy: registries[%d] (%s) has no keys", i, r.Prefix)
+		}
+		for j, k := range r.Keys {
+			pub, err := parsePublicKeyPEM(k)
+			if err != nil {
+				return nil, fmt.Errorf("cosign policy: registries[%d].keys[%d]: %w", i, j, err)
+			}
+			r.publicKeys = append(r.publicKeys, pub)
+		}
+	}
+
+	// najdłuższy prefiks wygrywa
+	sort.SliceStable(p.Registries, func(i, j int) bool {
+		return len(p.Registries[i].Prefix) > len(p.Registries[j].Prefix)
+	})
+	return p, nil
+}
+
+func parsePublicKeyPEM(s string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(s)))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// normalizeImagePrefix – prefiks w tej samej postaci co rejestr/repozytorium z
+// parseImageRef: bez hosta -> docker.io, pojedyncza nazwa w Docker Hub -> library/
+func normalizeImagePrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	registry, rest := defaultRegistry, prefix
+	if i := strings.Index(prefix, "/"); i >= 0 {
+		first := prefix[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry, rest = first, prefix[i+1:]
+		}
+	}
+	if registry == defaultRegistry && rest != "" && !strings.Contains(rest, "/") {
+		rest = "library/" + rest
+	}
+	return registry + "/" + rest
+}
+
+// match – polityka dla obrazu (po prefiksie znormalizowanej referencji
+// rejestr/repozytorium), nil gdy obraz nie podlega weryfikacji
+func (p *cosignPolicy) match(ref imageRef) *cosignRegistryPolicy {
+	name := ref.Registry + "/" + ref.Repository
+	for i := range p.Registries {
+		if strings.HasPrefix(name, p.Registries[i].Prefix) {
+			return &p.Registries[i]
+		}
+	}
+	return nil
+}
+
+// cosignVerifier sprawdza podpisy cosign (klucz publiczny, format "simple signing")
+// bezpośrednio w rejestrze: manifest pod tagiem sha256-<hex>.sig, warstwy z
+// anotacją dev.cosignproject.cosign/signature. Wyniki cache'owane po digeście.
+type cosignVerifier struct {
+	policy   *cosignPolicy
+	client   registryClient
+	resolver digestResolver
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cosignCacheEntry
+}
+
+type cosignCacheEntry struct {
+	err     error
+	expires time.Time
+}
+
+func newCosignVerifier(policy *cosignPolicy, client registryClient, resolver digestResolver, ttl time.Duration) *cosignVerifier {
+	return &cosignVerifier{
+		policy:   policy,
+		client:   client,
+		resolver: resolver,
+		ttl:      ttl,
+		cache:    map[string]cosignCacheEntry{},
+	}
+}
+
+// Verify zwraca nil dla obrazów bez polityki albo z poprawnym podpisem
+func (v *cosignVerifier) Verify(ctx context.Context, image string) error {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		// niepoprawną referencję zgłasza validateImage
+		return nil
+	}
+	pol := v.policy.match(ref)
+	if pol == nil {
+		return nil
+	}
+
+	digest := ref.Digest
+	if digest == "" {
+		if digest, err = v.resolver.Resolve(ctx, ref); err != nil {
+			return fmt.Errorf("resolve digest: %w", err)
+		}
+	}
+
+	key := pol.Prefix + "|" + ref.Registry + "/" + ref.Repository + "@" + digest
+	v.mu.Lock()
+	e, ok := v.cache[key]
+	v.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.err
+	}
+
+	err = v.verifyDigest(ctx, ref, digest, pol)
+
+	// porażki trzymamy krócej – mogą wynikać z chwilowej niedostępności rejestru
+	ttl := v.ttl
+	if err != nil && ttl > time.Minute {
+		ttl = time.Minute
+	}
+	v.mu.Lock()
+	v.cache[key] = cosignCacheEntry{err: err, expires: time.Now().Add(ttl)}
+	v.mu.Unlock()
+	return err
+}
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+func (v *cosignVerifier) verifyDigest(ctx context.Context, ref imageRef, digest string, pol *cosignRegistryPolicy) error {
+	sigTag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	raw, err := v.client.Manifest(ctx, ref, sigTag)
+	if err != nil {
+		return fmt.Errorf("no cosign signature found (%s): %w", sigTag, err)
+	}
+
+	var m ociManifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("decode signature manifest: %w", err)
+	}
+
+	var lastErr error = fmt.Errorf("signature manifest %s has no signature layers", sigTag)
+	for _, l := range m.Layers {
+		sigB64, ok := l.Annotations[cosignSignatureAnnotation]
+		if !ok || l.MediaType != cosignSimpleSigningType {
+			continue
+		}
+		payload, err := v.client.Blob(ctx, ref, l.Digest)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := verifySimpleSigning(payload, sigB64, digest, pol.publicKeys); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// verifySimpleSigning – podpis payloadu dowolnym z kluczy + zgodność digesta w payloadzie
+func verifySimpleSigning(payload []byte, sigB64, digest string, keys []crypto.PublicKey) error {
+	var p simpleSigningPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("decode signature payload: %w", err)
+	}
+	if p.Critical.Type != "cosign container image signature" {
+		return fmt.Errorf("unexpected signature payload type %q", p.Critical.Type)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature payload is for %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	sum := sha256.Sum256(payload)
+	for _, k := range keys {
+		switch pub := k.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(pub, sum[:], sig) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(pub, payload, sig) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("signature does not match any configured key")
+}
+
+// cosignEnforced – czy namespace jest na liście COSIGN_NAMESPACES
+func cosignEnforced(ns *corev1.Namespace) bool {
+	for _, n := range strings.Split(cosignNamespaces, ",") {
+		if strings.TrimSpace(n) == ns.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImageSignatures – walidacja podpisów wszystkich obrazów z podTarget
+func validateImageSignatures(t *podTarget, ns *corev1.Namespace) field.ErrorList {
+	if imageVerifier == nil || !cosignEnforced(ns) {
+		return nil
+	}
+
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+
+	var errs field.ErrorList
+	check := func(containers []corev1.Container, fp *field.Path) {
+		for i, c := range containers {
+			if err := imageVerifier.Verify(ctx, c.Image); err != nil {
+				errs = append(errs, field.Forbidden(fp.Index(i).Child("image"),
+					fmt.Sprintf("weryfikacja podpisu cosign dla %q nie powiodła się: %v", c.Image, err)))
+			}
+		}
+	}
+	check(t.Spec.InitContainers, t.field("spec", "initContainers"))
+	check(t.Spec.Containers, t.field("spec", "containers"))
+	return errs
+}
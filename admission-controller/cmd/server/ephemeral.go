@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kontenery efemeryczne (kubectl debug) – podpięcie tcpdumpa do działającego
+// UPF bez restartu Poda i bez zrywania sesji PDU. Webhook dostaje UPDATE
+// subresource'u pods/ephemeralcontainers; sprawdzamy tylko nowe kontenery.
+
+var (
+	// DEBUG_IMAGES – dozwolone obrazy debug (pełna nazwa albo prefiks zakończony "/" lub ":")
+	debugImages = getEnv("DEBUG_IMAGES", tcpdumpImage+",docker.io/nicolaka/netshoot:")
+	// kto może dodawać kontenery efemeryczne (UserInfo z AdmissionRequest)
+	debugAllowedGroups = getEnv("DEBUG_ALLOWED_GROUPS", "system:masters,free5gc-debuggers")
+	debugAllowedUsers  = getEnv("DEBUG_ALLOWED_USERS", "")
+)
+
+const ephemeralContainersSubresource = "ephemeralcontainers"
+
+func isEphemeralContainersRequest(req *admissionv1.AdmissionRequest) bool {
+	return req.Kind.Kind == "Pod" && req.SubResource == ephemeralContainersSubresource
+}
+
+// newEphemeralContainers – indeksy kontenerów, których nie było w starym Podzie
+func newEphemeralContainers(pod, old *corev1.Pod) []int {
+	existing := map[string]bool{}
+	if old != nil {
+		for _, ec := range old.Spec.EphemeralContainers {
+			existing[ec.Name] = true
+		}
+	}
+	var idx []int
+	for i, ec := range pod.Spec.EphemeralContainers {
+		if !existing[ec.Name] {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func decodeEphemeralRequest(req *admissionv1.AdmissionRequest) (*corev1.Pod, *corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if _, _, err := deserializer.Decode(req.Object.Raw, nil, pod); err != nil {
+		return nil, nil, fmt.Errorf("decode pod: %w", err)
+	}
+	var old *corev1.Pod
+	if len(req.OldObject.Raw) > 0 {
+		old = &corev1.Pod{}
+		if _, _, err := deserializer.Decode(req.OldObject.Raw, nil, old); err != nil {
+			return nil, nil, fmt.Errorf("decode old pod: %w", err)
+		}
+	}
+	return pod, old, nil
+}
+
+// isDebugImageAllowed – dokładna nazwa z DEBUG_IMAGES albo prefiks ("repo:" / "registry/");
+// obie strony normalizowane (docker.io, library/, brak tagu = latest), jak prefiksy w cosign.go
+func isDebugImageAllowed(image string) bool {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return false
+	}
+	name := ref.Registry + "/" + ref.Repository
+	for _, a := range splitList(debugImages) {
+		switch {
+		case strings.HasSuffix(a, "/"):
+			if strings.HasPrefix(name, normalizeImagePrefix(a)) {
+				return true
+			}
+		case strings.HasSuffix(a, ":"):
+			// dowolny tag/digest repozytorium
+			if r, err := parseImageRef(strings.TrimSuffix(a, ":")); err == nil && r.Registry+"/"+r.Repository == name {
+				return true
+			}
+		default:
+			if r, err := parseImageRef(a); err == nil && canonicalImageRef(r) == canonicalImageRef(ref) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canonicalImageRef – rejestr/repozytorium[:tag][@digest], brak tagu i digesta = :latest
+func canonicalImageRef(ref imageRef) string {
+	s := ref.Registry + "/" + ref.Repository
+	if ref.Tag != "" {
+		s += ":" + ref.Tag
+	} else if ref.Digest == "" {
+		s += ":latest"
+	}
+	if ref.Digest != "" {
+		s += "@" + ref.Digest
+	}
+	return s
+}
+
+func isDebugUserAllowed(user string, groups []string) bool {
+	for _, u := range strings.Split(debugAllowedUsers, ",") {
+		if u = strings.TrimSpace(u); u != "" && u == user {
+			return true
+		}
+	}
+	for _, g := range strings.Split(debugAllowedGroups, ",") {
+		g = strings.TrimSpace(g)
+		for _, ug := range groups {
+			if g != "" && g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// --------- MUTATING ---------
+
+// mutateEphemeralContainers dopisuje zmienne slice'a (jak w sidecarze tcpdump)
+// do nowych kontenerów debug z dozwolonych obrazów
+func mutateEphemeralContainers(req *admissionv1.AdmissionRequest, clientset kubernetes.Interface) ([]byte, error) {
+	pod, old, err := decodeEphemeralRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	shouldHandle, _, err := shouldHandleNamespace(context.Background(), clientset, req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !shouldHandle {
+		return nil, nil
+	}
+
+	original := pod.DeepCopy()
+	for _, i := range newEphemeralContainers(pod, old) {
+		ec := &pod.Spec.EphemeralContainers[i]
+		if !isDebugImageAllowed(ec.Image) {
+			continue
+		}
+		for _, env := range sliceEnvVars(pod.Annotations) {
+			if !hasEnv(ec.Env, env.Name) {
+				ec.Env = append(ec.Env, env)
+			}
+		}
+	}
+
+	return marshalPatch(req.Object.Raw, original, pod)
+}
+
+func hasEnv(envs []corev1.EnvVar, name string) bool {
+	for _, e := range envs {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// --------- VALIDATING ---------
+
+// validateEphemeralContainers – nowe kontenery efemeryczne: tylko uprawnieni
+// użytkownicy, tylko obrazy z DEBUG_IMAGES, z capabilities co najwyżej
+// NET_ADMIN/NET_RAW (i to tylko w namespace z allow-netadmin=true)
+func validateEphemeralContainers(req *admissionv1.AdmissionRequest, clientset kubernetes.Interface) field.ErrorList {
+	pod, old, err := decodeEphemeralRequest(req)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("spec", "ephemeralContainers"), err)}
+	}
+
+	shouldHandle, nsObj, err := shouldHandleNamespace(context.Background(), clientset, req.Namespace)
+	if err != nil {
+		return field.ErrorList{
+			field.Invalid(field.NewPath("metadata", "namespace"), req.Namespace, err.Error()),
+		}
+	}
+	if !shouldHandle {
+		return nil
+	}
+
+	added := newEphemeralContainers(pod, old)
+	if len(added) == 0 {
+		return nil
+	}
+
+	var errs field.ErrorList
+	fp := field.NewPath("spec", "ephemeralContainers")
+
+	if !isDebugUserAllowed(req.UserInfo.Username, req.UserInfo.Groups) {
+		errs = append(errs, field.Forbidden(fp,
+			fmt.Sprintf("użytkownik %q nie może dodawać kontenerów debug (DEBUG_ALLOWED_USERS / DEBUG_ALLOWED_GROUPS)", req.UserInfo.Username)))
+	}
+
+	netAdminAllowed := nsObj.Labels != nil && strings.ToLower(nsObj.Labels[allowNetAdminNsLabel]) == "true"
+	for _, i := range added {
+		ec := pod.Spec.EphemeralContainers[i]
+		efp := fp.Index(i)
+
+		if !isDebugImageAllowed(ec.Image) {
+			errs = append(errs, field.Forbidden(efp.Child("image"),
+				fmt.Sprintf("obraz %q nie jest na liście DEBUG_IMAGES", ec.Image)))
+		}
+
+		sc := ec.SecurityContext
+		if sc == nil {
+			continue
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			errs = append(errs, field.Forbidden(efp.Child("securityContext", "privileged"),
+				"kontenery debug nie mogą być privileged"))
+		}
+		if sc.Capabilities == nil {
+			continue
+		}
+		for _, c := range sc.Capabilities.Add {
+			switch c {
+			case "NET_ADMIN", "NET_RAW":
+				if !netAdminAllowed {
+					errs = append(errs, field.Forbidden(efp.Child("securityContext", "capabilities", "add"),
+						"NET_ADMIN / NET_RAW wymaga labela namespace'u allow-netadmin=true"))
+				}
+			default:
+				errs = append(errs, field.NotSupported(efp.Child("securityContext", "capabilities", "add"),
+					string(c), []string{"NET_ADMIN", "NET_RAW"}))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Eventy Kubernetesa: odmowa webhooka, nieblokujące ostrzeżenia walidatora
+// (np. upNode SMF bez UPF-a), naruszenia z audytu w tle i istotne
+// mutacje (sidecar tcpdump, init container gtp5g, pinowanie digestów) zostają
+// widoczne w kubectl describe / kubectl events, a nie tylko w komunikacie
+// kubectl apply. Event trafia na workload-właściciela (ownerReference
+// z controller=true), sam obiekt (gdy już istnieje), a w pozostałych
+// przypadkach (np. CREATE Deploymentu) na namespace. Limit per obiekt (token
+// bucket z korelatora client-go) tłumi burze eventów od kontrolerów
+// ponawiających tworzenie Podów.
+
+var (
+	// ADMISSION_EVENTS=false – bez eventów
+	admissionEventsEnabled = getEnvBool("ADMISSION_EVENTS", true)
+	// ADMISSION_EVENTS_BURST / _INTERVAL – do BURST eventów na obiekt, potem jeden co INTERVAL
+	admissionEventsBurst    = getEnvInt("ADMISSION_EVENTS_BURST", 10)
+	admissionEventsInterval = getEnvDuration("ADMISSION_EVENTS_INTERVAL", time.Minute)
+
+	// ustawiany w main(); nil = eventy wyłączone
+	eventRecorder record.EventRecorder
+)
+
+const (
+	eventReasonDenied          = "AdmissionDenied"
+	eventReasonWarning         = "AdmissionWarning"
+	eventReasonAuditViolation  = "PolicyViolation"
+	eventReasonSidecarInjected = "SidecarInjected"
+	eventReasonInitInjected    = "InitContainerInjected"
+	eventReasonDigestPinned    = "ImageDigestPinned"
+
+	// limit message w Evencie (API tnie do 1024 B)
+	eventMessageMax = 1000
+)
+
+// startEventRecorder – broadcaster z limitem per obiekt, zapis przez API
+func startEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	opts := record.CorrelatorOptions{BurstSize: admissionEventsBurst}
+	if admissionEventsInterval > 0 {
+		opts.QPS = float32(1 / admissionEventsInterval.Seconds())
+	}
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(opts)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(runtime.NewScheme(), corev1.EventSource{Component: "admission-webhook"})
+}
+
+func emitEvent(ref *corev1.ObjectReference, eventType, reason, msg string) {
+	if eventRecorder == nil || ref == nil {
+		return
+	}
+	if len(msg) > eventMessageMax {
+		msg = msg[:eventMessageMax-3] + "..."
+	}
+	eventRecorder.Event(ref, eventType, reason, msg)
+}
+
+// eventTarget – controller-owner, obiekt (exists – zapisany w API) albo namespace
+func eventTarget(apiVersion, kind, namespace string, meta *metav1.ObjectMeta, exists bool) *corev1.ObjectReference {
+	if meta != nil {
+		for _, o := range meta.OwnerReferences {
+			if o.Controller != nil && *o.Controller {
+				return &corev1.ObjectReference{
+					APIVersion: o.APIVersion,
+					Kind:       o.Kind,
+					Namespace:  namespace,
+					Name:       o.Name,
+					UID:        o.UID,
+				}
+			}
+		}
+		if exists && meta.Name != "" {
+			return &corev1.ObjectReference{
+				APIVersion: apiVersion,
+				Kind:       kind,
+				Namespace:  namespace,
+				Name:       meta.Name,
+				UID:        meta.UID,
+			}
+		}
+	}
+	if namespace == "" {
+		return nil
+	}
+	return &corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: namespace, Namespace: namespace}
+}
+
+// requestEventTarget – cel eventu dla AdmissionRequest (bez eventów dla dry-run)
+func requestEventTarget(req *admissionv1.AdmissionRequest, exists bool) *corev1.ObjectReference {
+	if eventRecorder == nil || (req.DryRun != nil && *req.DryRun) {
+		return nil
+	}
+	raw := req.Object.Raw
+	if len(raw) == 0 {
+		raw = req.OldObject.Raw
+	}
+	var obj struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			log.Printf("event target %s/%s: %v", req.Namespace, req.Name, err)
+		}
+	}
+	if obj.Metadata.Name == "" {
+		obj.Metadata.Name = req.Name
+	}
+	// podzasób (pods/ephemeralcontainers) – obiekt nadrzędny zawsze istnieje
+	if req.SubResource != "" {
+		exists = true
+	}
+	apiVersion := req.Kind.Version
+	if req.Kind.Group != "" {
+		apiVersion = req.Kind.Group + "/" + apiVersion
+	}
+	return eventTarget(apiVersion, req.Kind.Kind, req.Namespace, &obj.Metadata, exists)
+}
+
+// recordDenial – Warning AdmissionDenied z komunikatami walidatora
+func recordDenial(req *admissionv1.AdmissionRequest, msg string) {
+	ref := requestEventTarget(req, req.Operation != admissionv1.Create)
+	if ref == nil {
+		return
+	}
+	what := strings.ToLower(req.Kind.Kind)
+	if name := req.Name; name != "" {
+		what += "/" + name
+	}
+	emitEvent(ref, corev1.EventTypeWarning, eventReasonDenied,
+		fmt.Sprintf("%s %s odrzucony: %s", req.Operation, what, msg))
+}
+
+// recordWarnings – Warning AdmissionWarning z ostrzeżeniami, które webhook
+// zwrócił w AdmissionResponse.Warnings (zapis nie jest blokowany)
+func recordWarnings(req *admissionv1.AdmissionRequest, warns field.ErrorList) {
+	if len(warns) == 0 {
+		return
+	}
+	ref := requestEventTarget(req, req.Operation != admissionv1.Create)
+	if ref == nil {
+		return
+	}
+	msgs := make([]string, 0, len(warns))
+	for _, e := range warns {
+		msgs = append(msgs, e.Error())
+	}
+	what := strings.ToLower(req.Kind.Kind)
+	if name := req.Name; name != "" {
+		what += "/" + name
+	}
+	emitEvent(ref, corev1.EventTypeWarning, eventReasonWarning,
+		fmt.Sprintf("%s %s: %s", req.Operation, what, strings.Join(msgs, "; ")))
+}
+
+// mutationNote – istotna zmiana wprowadzona przez mutator (powód eventu + opis)
+type mutationNote struct {
+	Reason  string
+	Message string
+}
+
+type podSpecSnapshot struct {
+	containers map[string]string // nazwa -> obraz
+	inits      map[string]string
+}
+
+func snapshotPodSpec(spec *corev1.PodSpec) podSpecSnapshot {
+	s := podSpecSnapshot{containers: map[string]string{}, inits: map[string]string{}}
+	for _, c := range spec.Containers {
+		s.containers[c.Name] = c.Image
+	}
+	for _, c := range spec.InitContainers {
+		s.inits[c.Name] = c.Image
+	}
+	return s
+}
+
+// podSpecMutations – dodane kontenery / init containery i obrazy przypięte do digestu
+func podSpecMutations(before podSpecSnapshot, spec *corev1.PodSpec) []mutationNote {
+	var notes []mutationNote
+	var pinned []string
+	check := func(cs []corev1.Container, prev map[string]string, reason, what string) {
+		for _, c := range cs {
+			img, ok := prev[c.Name]
+			switch {
+			case !ok:
+				notes = append(notes, mutationNote{reason, fmt.Sprintf("dodano %s %s (%s)", what, c.Name, c.Image)})
+			case img != c.Image && strings.Contains(c.Image, "@sha256:"):
+				pinned = append(pinned, fmt.Sprintf("%s: %s", c.Name, c.Image))
+			}
+		}
+	}
+	check(spec.InitContainers, before.inits, eventReasonInitInjected, "init container")
+	check(spec.Containers, before.containers, eventReasonSidecarInjected, "kontener")
+	if len(pinned) > 0 {
+		notes = append(notes, mutationNote{eventReasonDigestPinned, "obrazy przypięte do digestu: " + strings.Join(pinned, ", ")})
+	}
+	return notes
+}
+
+// recordMutations – Normal event per istotna mutacja
+func recordMutations(req *admissionv1.AdmissionRequest, notes []mutationNote) {
+	if len(notes) == 0 {
+		return
+	}
+	// mutacja poprzedza zapis: przy CREATE obiektu jeszcze nie ma, więc event
+	// trafia na istniejącego właściciela (np. ReplicaSet tworzący Pod) albo
+	// na namespace – wtedy z nazwą obiektu w treści
+	ref := requestEventTarget(req, req.Operation != admissionv1.Create)
+	if ref == nil {
+		return
+	}
+	prefix := ""
+	if ref.Kind == "Namespace" {
+		prefix = strings.ToLower(req.Kind.Kind)
+		if name := req.Name; name != "" {
+			prefix += "/" + name
+		}
+		prefix += ": "
+	}
+	for _, n := range notes {
+		emitEvent(ref, corev1.EventTypeNormal, n.Reason, prefix+n.Message)
+	}
+}
+
+// recordAuditViolation – Warning na obiekcie z naruszeniami znalezionymi przez audyt
+func recordAuditViolation(ref corev1.ObjectReference, errs field.ErrorList) {
+	if eventRecorder == nil {
+		return
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if e.Type != field.ErrorTypeInternal {
+			msgs = append(msgs, e.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	emitEvent(&ref, corev1.EventTypeWarning, eventReasonAuditViolation,
+		fmt.Sprintf("audyt: %d naruszeń reguł admission: %s", len(msgs), strings.Join(msgs, "; ")))
+}
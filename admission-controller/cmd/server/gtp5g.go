@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// gtp5g: UPF free5gc wymaga modułu jądra gtp5g (i tun) na węźle. Bez niego
+// UPF crashloopuje z mało czytelnym błędem, więc:
+//   - GTP5G_CHECK=true – init container sprawdzający moduł przed startem UPF,
+//   - GTP5G_NODE_LABEL=k=v – nodeSelector UPF musi wskazywać tylko węzły z tym labelem.
+
+var (
+	gtp5gCheck      = getEnvBool("GTP5G_CHECK", false)
+	gtp5gCheckImage = getEnv("GTP5G_CHECK_IMAGE", "docker.io/library/busybox:1.36")
+	gtp5gNodeLabel  = getEnv("GTP5G_NODE_LABEL", "")
+
+	// cache węzłów z informera; ustawiany w main(), gdy GTP5G_NODE_LABEL jest ustawiony
+	nodeLister corelisters.NodeLister
+)
+
+const gtp5gCheckContainerName = "gtp5g-check"
+
+// moduły widać w /sys (sysfs jądra węzła), więc nie trzeba hostPath
+const gtp5gCheckScript = `set -e
+if [ ! -d /sys/module/gtp5g ]; then
+  echo "gtp5g kernel module is not loaded on node ${NODE_NAME} (modprobe gtp5g)" >&2
+  exit 1
+fi
+if [ ! -e /sys/class/misc/tun ] && [ ! -d /sys/module/tun ]; then
+  echo "tun device (/dev/net/tun) is not available on node ${NODE_NAME} (modprobe tun)" >&2
+  exit 1
+fi
+echo "gtp5g $(cat /sys/module/gtp5g/version 2>/dev/null || echo loaded), tun ok on ${NODE_NAME}"
+`
+
+// ensureGtp5gInitContainer – init container gtp5g-check na końcu listy
+// (dopisanie daje jedną operację add w patchu zamiast replace per element;
+// kontrola i tak kończy się przed startem kontenerów UPF-a)
+func ensureGtp5gInitContainer(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	if hasContainer(spec.InitContainers, gtp5gCheckContainerName) {
+		return
+	}
+
+	c := corev1.Container{
+		Name:    gtp5gCheckContainerName,
+		Image:   gtp5gCheckImage,
+		Command: []string{"sh", "-c", gtp5gCheckScript},
+		Env: []corev1.EnvVar{{
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			},
+		}},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsNonRoot:           boolPtr(true),
+			RunAsUser:              int64Ptr(65534),
+			ReadOnlyRootFilesystem: boolPtr(true),
+		},
+	}
+	applyResourceSpec(&c, resourceProfiles.containerSpec(meta, &c, false, true))
+	ensureSecurityContext(&c)
+
+	spec.InitContainers = append(spec.InitContainers, c)
+}
+
+// parseGtp5gNodeLabel – "key=value" albo samo "key" (dowolna wartość)
+func parseGtp5gNodeLabel() (string, string, bool) {
+	raw := strings.TrimSpace(gtp5gNodeLabel)
+	if raw == "" {
+		return "", "", false
+	}
+	k, v, hasValue := strings.Cut(raw, "=")
+	if !hasValue {
+		return k, "", true
+	}
+	return k, v, true
+}
+
+func nodeHasGtp5g(n *corev1.Node, key, value string) bool {
+	v, ok := n.Labels[key]
+	return ok && (value == "" || v == value)
+}
+
+// validateGtp5gNodeSelector – UPF z nodeSelector może trafić tylko na węzły
+// z labelem GTP5G_NODE_LABEL (sprawdzane w cache węzłów z informera)
+func validateGtp5gNodeSelector(t *podTarget) field.ErrorList {
+	key, value, ok := parseGtp5gNodeLabel()
+	if !ok || nodeLister == nil || len(t.Spec.NodeSelector) == 0 {
+		return nil
+	}
+	fp := t.field("spec", "nodeSelector")
+
+	// nodeSelector sam wymaga labela gtp5g – nic więcej nie trzeba sprawdzać
+	if v, ok := t.Spec.NodeSelector[key]; ok && (value == "" || v == value) {
+		return nil
+	}
+
+	nodes, err := nodeLister.List(labels.SelectorFromSet(t.Spec.NodeSelector))
+	if err != nil {
+		return field.ErrorList{field.InternalError(fp, fmt.Errorf("list nodes: %w", err))}
+	}
+	if len(nodes) == 0 {
+		return field.ErrorList{field.Invalid(fp, t.Spec.NodeSelector,
+			fmt.Sprintf("nodeSelector UPF nie pasuje do żadnego węzła z labelem %s", gtp5gNodeLabel))}
+	}
+
+	var missing []string
+	for _, n := range nodes {
+		if !nodeHasGtp5g(n, key, value) {
+			missing = append(missing, n.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return field.ErrorList{field.Forbidden(fp,
+		fmt.Sprintf("nodeSelector UPF obejmuje węzły bez labela %s (brak modułu gtp5g?): %s",
+			gtp5gNodeLabel, strings.Join(missing, ", ")))}
+}
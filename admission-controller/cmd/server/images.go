@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// REQUIRE_IMAGE_DIGEST – w namespace free5gc obrazy muszą być przypięte @sha256:
+	requireImageDigest = getEnvBool("REQUIRE_IMAGE_DIGEST", false)
+	// RESOLVE_IMAGE_DIGEST – mutator zamienia tag na digest przez registry API
+	resolveImageDigest = getEnvBool("RESOLVE_IMAGE_DIGEST", false)
+	digestCacheTTL     = getEnvDuration("DIGEST_CACHE_TTL", 10*time.Minute)
+	// DIGEST_CACHE_SIZE – maks. liczba wpisów tag->digest w cache
+	digestCacheSize = getEnvInt("DIGEST_CACHE_SIZE", 1024)
+
+	sha256DigestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	sha512DigestRegex = regexp.MustCompile(`^sha512:[a-f0-9]{128}$`)
+	// gramatyka digesta OCI (image-spec descriptor.md): algorithm ":" encoded
+	ociDigestRegex = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+
+	// wspólny klient rejestru i resolver (z cache); podmienialne np. na fake registry
+	registryAPI   registryClient = newHTTPRegistryClient()
+	imageResolver digestResolver = newCachingResolver(registryAPI, digestCacheTTL, digestCacheSize)
+)
+
+const defaultRegistry = "docker.io"
+
+// imageRef – rozłożona referencja obrazu: [registry/]repository[:tag][@digest]
+type imageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageRef rozkłada referencję obrazu tak jak docker/containerd:
+// host rejestru (z portem) to pierwszy segment zawierający "." lub ":"
+// albo "localhost", tag to ":" po ostatnim "/", digest po "@".
+func parseImageRef(image string) (imageRef, error) {
+	var ref imageRef
+	name := strings.TrimSpace(image)
+	if name == "" {
+		return ref, fmt.Errorf("empty image reference")
+	}
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !validDigest(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q", ref.Digest)
+		}
+	}
+
+	if i := strings.LastIndex(name, ":"); i >= 0 && i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if ref.Tag == "" {
+			return ref, fmt.Errorf("empty tag in %q", image)
+		}
+	}
+
+	ref.Registry = defaultRegistry
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.Registry = first
+			name = name[i+1:]
+		}
+	}
+	if ref.Registry == defaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" {
+		return ref, fmt.Errorf("empty repository in %q", image)
+	}
+	ref.Repository = name
+
+	return ref, nil
+}
+
+// validDigest – dowolny digest OCI; dla zarejestrowanych algorytmów (sha256,
+// sha512) sprawdzana jest też długość i kodowanie hex
+func validDigest(d string) bool {
+	switch {
+	case strings.HasPrefix(d, "sha256:"):
+		return sha256DigestRegex.MatchString(d)
+	case strings.HasPrefix(d, "sha512:"):
+		return sha512DigestRegex.MatchString(d)
+	}
+	return ociDigestRegex.MatchString(d)
+}
+
+// isLatest – obraz bez digesta i z tagiem "latest" albo bez tagu
+func (r imageRef) isLatest() bool {
+	return r.Digest == "" && (r.Tag == "" || r.Tag == "latest")
+}
+
+// validateImage – reguły dotyczące referencji obrazu w kontenerze
+func validateImage(c *corev1.Container, fp *field.Path, ns *corev1.Namespace) field.ErrorList {
+	var errs field.ErrorList
+
+	ref, err := parseImageRef(c.Image)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fp.Child("image"), c.Image, err.Error())}
+	}
+
+	// zakaz :latest (opcjonalny, sterowany env)
+	if denyLatestTag && ref.isLatest() {
+		errs = append(errs, field.Forbidden(fp.Child("image"),
+			"image tag ':latest' (lub brak taga) jest zabroniony – użyj konkretnej wersji"))
+	}
+
+	// wymóg digesta w free5gc (obrazy NF mają być niezmienne); przypięcie
+	// wymaga sha256 – tylko taki digest mutator dopisuje i rejestr weryfikuje
+	if requireImageDigest && ns.Name == "free5gc" && !sha256DigestRegex.MatchString(ref.Digest) {
+		errs = append(errs, field.Forbidden(fp.Child("image"),
+			fmt.Sprintf("obraz %q musi być przypięty digestem (@sha256:...) w namespace 'free5gc'", c.Image)))
+	}
+
+	return errs
+}
+
+// --------- MUTATING: tag -> digest ---------
+
+// pinImageDigests dopisuje @sha256 do obrazów bez digesta. Błąd rejestru nie
+// blokuje admission – obraz zostaje bez zmian, a ewentualny REQUIRE_IMAGE_DIGEST
+// odrzuci go w walidacji.
+func pinImageDigests(ctx context.Context, spec *corev1.PodSpec, resolver digestResolver) {
+	pin := func(containers []corev1.Container) {
+		for i := range containers {
+			c := &containers[i]
+			ref, err := parseImageRef(c.Image)
+			if err != nil || ref.Digest != "" {
+				continue
+			}
+			digest, err := resolver.Resolve(ctx, ref)
+			if err != nil {
+				log.Printf("resolve digest for %s: %v", c.Image, err)
+				continue
+			}
+			c.Image = c.Image + "@" + digest
+		}
+	}
+	pin(spec.InitContainers)
+	pin(spec.Containers)
+}
+
+// digestResolver – zamiana tagu na digest; interfejs, żeby dało się podstawić
+// lokalny/fake rejestr zamiast prawdziwego registry API
+type digestResolver interface {
+	Resolve(ctx context.Context, ref imageRef) (string, error)
+}
+
+// cachingResolver – cache tag->digest z TTL, żeby nie pytać rejestru przy każdym Podzie;
+// przy pełnym cache najpierw wypadają przeterminowane wpisy, potem najstarszy
+type cachingResolver struct {
+	next    digestResolver
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]digestCacheEntry
+}
+
+type digestCacheEntry struct {
+	digest  string
+	expires time.Time
+}
+
+func newCachingResolver(next digestResolver, ttl time.Duration, maxSize int) *cachingResolver {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return &cachingResolver{
+		next:    next,
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: map[string]digestCacheEntry{},
+	}
+}
+
+func (c *cachingResolver) Resolve(ctx context.Context, ref imageRef) (string, error) {
+	// obraz bez tagu to :latest – jeden wpis dla obu zapisów
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	key := ref.Registry + "/" + ref.Repository + ":" + tag
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.digest, nil
+	}
+
+	digest, err := c.next.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxSize {
+		c.evictLocked(time.Now())
+	}
+	c.entries[key] = digestCacheEntry{digest: digest, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return digest, nil
+}
+
+// evictLocked – usuwa przeterminowane wpisy, a gdy to nie zwolni miejsca, ten
+// z najwcześniejszym wygaśnięciem (najdawniej pobrany); wołane pod c.mu
+func (c *cachingResolver) evictLocked(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldestKey == "" || e.expires.Before(oldest) {
+			oldestKey, oldest = k, e.expires
+		}
+	}
+	if len(c.entries) >= c.maxSize && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// Cache obiektów z informerów wspólnej fabryki z main(). Reguły porównujące
+// obiekt z resztą namespace'u (Service -> Pody, SMF <-> UPF), sprawdzenie
+// namespace'u i audyt w tle czytają z cache zamiast listować przez API przy
+// każdym żądaniu admission (a w audycie – przy każdym obiekcie).
+
+var (
+	// ustawiane w registerListers(); nil = reguły korelujące pomijane
+	namespaceLister   corelisters.NamespaceLister
+	podLister         corelisters.PodLister
+	serviceLister     corelisters.ServiceLister
+	configMapLister   corelisters.ConfigMapLister
+	deploymentLister  appslisters.DeploymentLister
+	statefulSetLister appslisters.StatefulSetLister
+	daemonSetLister   appslisters.DaemonSetLister
+	// tylko dla audytu (AUDIT_ENABLED)
+	jobLister     batchlisters.JobLister
+	cronJobLister batchlisters.CronJobLister
+)
+
+// registerListers – informery rejestrowane w fabryce (przed factory.Start)
+func registerListers(factory informers.SharedInformerFactory) {
+	namespaceLister = factory.Core().V1().Namespaces().Lister()
+	podLister = factory.Core().V1().Pods().Lister()
+	serviceLister = factory.Core().V1().Services().Lister()
+	configMapLister = factory.Core().V1().ConfigMaps().Lister()
+	deploymentLister = factory.Apps().V1().Deployments().Lister()
+	statefulSetLister = factory.Apps().V1().StatefulSets().Lister()
+	daemonSetLister = factory.Apps().V1().DaemonSets().Lister()
+	if auditEnabled {
+		jobLister = factory.Batch().V1().Jobs().Lister()
+		cronJobLister = factory.Batch().V1().CronJobs().Lister()
+	}
+}
+
+// stripForCache – transform informerów: cache obejmuje cały klaster (Pody,
+// ConfigMapy, workloady), a managedFields i anotacja last-applied-configuration
+// z kubectl apply to często większość rozmiaru obiektu; żadna reguła ich nie
+// czyta. Update z obiektu bez managedFields zostawia je w API bez zmian.
+func stripForCache(obj interface{}) (interface{}, error) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		// np. cache.DeletedFinalStateUnknown
+		return obj, nil
+	}
+	m.SetManagedFields(nil)
+	if ann := m.GetAnnotations(); ann[corev1.LastAppliedConfigAnnotation] != "" {
+		delete(ann, corev1.LastAppliedConfigAnnotation)
+		m.SetAnnotations(ann)
+	}
+	return obj, nil
+}
+
+// getNamespace – namespace z cache; świeżo utworzonego może w nim jeszcze
+// nie być, wtedy GET z API
+func getNamespace(ctx context.Context, clientset kubernetes.Interface, name string) (*corev1.Namespace, error) {
+	if namespaceLister != nil {
+		ns, err := namespaceLister.Get(name)
+		if !apierrors.IsNotFound(err) {
+			return ns, err
+		}
+	}
+	return clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+// sortedByName – listery zwracają obiekty w losowej kolejności
+func sortedByName[T metav1.Object](items []T) []T {
+	sort.Slice(items, func(i, j int) bool { return items[i].GetName() < items[j].GetName() })
+	return items
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NetworkSlice (slicing.kkarczmarek.dev/v1alpha1) – jedno miejsce na opis
+// slice'a zamiast anotacji slice-id/sst/sd/dnn/ue-pool-cidr/n6-cidr
+// powielanych na każdym workloadzie. Workload wskazuje slice anotacją
+// 5g.kkarczmarek.dev/network-slice, mutator uzupełnia z niego brakujące
+// anotacje, walidator odrzuca nieznane slice'y i anotacje sprzeczne ze
+// slice'em, a kontroler zapisuje w status UPF-y i SMF-y podpięte do slice'a.
+
+var (
+	// NETWORK_SLICES=false – bez informera/kontrolera NetworkSlice
+	networkSlicesEnabled = getEnvBool("NETWORK_SLICES", true)
+	// SLICE_STATUS_RESYNC – pełne przeliczenie statusu niezależnie od zdarzeń
+	sliceStatusResync = getEnvDuration("SLICE_STATUS_RESYNC", 5*time.Minute)
+
+	networkSliceAnnotation = "5g.kkarczmarek.dev/network-slice"
+
+	networkSliceGVR = schema.GroupVersionResource{Group: "slicing.kkarczmarek.dev", Version: "v1alpha1", Resource: "networkslices"}
+
+	// ustawiane w main(), gdy CRD jest zainstalowane
+	sliceLister cache.GenericLister
+)
+
+type networkSliceSpec struct {
+	// SliceID – wartość anotacji slice-id (domyślnie nazwa obiektu)
+	SliceID string `json:"sliceId,omitempty"`
+	SNSSAI  struct {
+		SST int32  `json:"sst"`
+		SD  string `json:"sd,omitempty"`
+	} `json:"snssai"`
+	DNNs      []sliceDNN `json:"dnns"`
+	DataPlane struct {
+		N6CIDR string `json:"n6Cidr,omitempty"`
+		// Networks – dozwolone sieci w anotacji networks UPF-a (<nazwa>@IP/maska)
+		Networks []string `json:"networks,omitempty"`
+	} `json:"dataPlane,omitempty"`
+}
+
+type sliceDNN struct {
+	Name       string `json:"name"`
+	UEPoolCIDR string `json:"uePoolCidr,omitempty"`
+}
+
+type networkSliceStatus struct {
+	ObservedGeneration int64    `json:"observedGeneration,omitempty"`
+	Phase              string   `json:"phase"`
+	BoundUPFs          int64    `json:"boundUPFs"`
+	BoundSMFs          int64    `json:"boundSMFs"`
+	UPFs               []string `json:"upfs,omitempty"`
+	SMFs               []string `json:"smfs,omitempty"`
+}
+
+// networkSlice – nazwa + spec zdekodowany z obiektu unstructured
+type networkSlice struct {
+	Name string
+	Spec networkSliceSpec
+}
+
+func (s *networkSlice) sliceID() string {
+	if s.Spec.SliceID != "" {
+		return s.Spec.SliceID
+	}
+	return s.Name
+}
+
+func (s *networkSlice) dnn(name string) (sliceDNN, bool) {
+	for _, d := range s.Spec.DNNs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return sliceDNN{}, false
+}
+
+func (s *networkSlice) dnnNames() []string {
+	var out []string
+	for _, d := range s.Spec.DNNs {
+		out = append(out, d.Name)
+	}
+	return out
+}
+
+func networkSliceFrom(u *unstructured.Unstructured) (*networkSlice, error) {
+	s := &networkSlice{Name: u.GetName()}
+	spec, _, _ := unstructured.NestedMap(u.Object, "spec")
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &s.Spec); err != nil {
+		return nil, fmt.Errorf("NetworkSlice %s: %w", u.GetName(), err)
+	}
+	return s, nil
+}
+
+// getNetworkSlice – slice z cache informera; (nil, nil) gdy nie istnieje
+func getNetworkSlice(namespace, name string) (*networkSlice, error) {
+	if sliceLister == nil {
+		return nil, fmt.Errorf("NetworkSlice API (%s) is not available", networkSliceGVR.GroupVersion())
+	}
+	obj, err := sliceLister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T in NetworkSlice cache", obj)
+	}
+	return networkSliceFrom(u)
+}
+
+// sliceAnnotations – anotacje wynikające ze slice'a; DNN z anotacji
+// workloadu, a bez niej pierwszy z listy slice'a
+func sliceAnnotations(s *networkSlice, ann map[string]string) map[string]string {
+	out := map[string]string{
+		sliceIdAnnotation: s.sliceID(),
+		sstAnnotation:     strconv.Itoa(int(s.Spec.SNSSAI.SST)),
+	}
+	if s.Spec.SNSSAI.SD != "" {
+		out[sdAnnotation] = s.Spec.SNSSAI.SD
+	}
+	dnn, ok := s.dnn(strings.TrimSpace(ann[dnnAnnotation]))
+	if !ok && ann[dnnAnnotation] == "" && len(s.Spec.DNNs) > 0 {
+		dnn, ok = s.Spec.DNNs[0], true
+	}
+	if ok {
+		out[dnnAnnotation] = dnn.Name
+		if dnn.UEPoolCIDR != "" {
+			out[uePoolCidrAnnotation] = dnn.UEPoolCIDR
+		}
+	}
+	if s.Spec.DataPlane.N6CIDR != "" {
+		out[n6CidrAnnotation] = s.Spec.DataPlane.N6CIDR
+	}
+	return out
+}
+
+// ensureSliceAnnotations – brakujące anotacje slice'a ze wskazanego
+// NetworkSlice (przed copy5gAnnotationsToLabels, żeby objęły je labele);
+// nieznany slice zostawiamy walidatorowi
+func ensureSliceAnnotations(namespace string, meta *metav1.ObjectMeta) {
+	name := strings.TrimSpace(meta.Annotations[networkSliceAnnotation])
+	if name == "" {
+		return
+	}
+	s, err := getNetworkSlice(namespace, name)
+	if err != nil {
+		log.Printf("network slice %s/%s: %v", namespace, name, err)
+		return
+	}
+	if s == nil {
+		return
+	}
+	for k, v := range sliceAnnotations(s, meta.Annotations) {
+		if meta.Annotations[k] == "" {
+			meta.Annotations[k] = v
+		}
+	}
+}
+
+// validateNetworkSliceRef – slice musi istnieć, a anotacje workloadu
+// muszą się z nim zgadzać
+func validateNetworkSliceRef(t *podTarget, namespace string) field.ErrorList {
+	name := strings.TrimSpace(t.annotation(networkSliceAnnotation))
+	if name == "" {
+		return nil
+	}
+	fp := t.field("metadata", "annotations")
+	s, err := getNetworkSlice(namespace, name)
+	if err != nil {
+		return field.ErrorList{field.Forbidden(fp.Key(networkSliceAnnotation), err.Error())}
+	}
+	if s == nil {
+		return field.ErrorList{field.NotFound(fp.Key(networkSliceAnnotation),
+			fmt.Sprintf("NetworkSlice %s/%s", namespace, name))}
+	}
+
+	var errs field.ErrorList
+	ann := t.Meta.Annotations
+	if v := strings.TrimSpace(ann[dnnAnnotation]); v != "" {
+		if _, ok := s.dnn(v); !ok {
+			errs = append(errs, field.NotSupported(fp.Key(dnnAnnotation), v, s.dnnNames()))
+		}
+	}
+	want := sliceAnnotations(s, ann)
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got := strings.TrimSpace(ann[k])
+		if got == "" || sameSliceValue(k, got, want[k]) {
+			continue
+		}
+		errs = append(errs, field.Invalid(fp.Key(k), got,
+			fmt.Sprintf("NetworkSlice %s wymaga %s", name, want[k])))
+	}
+
+	// sieci płaszczyzny danych w anotacji networks UPF-a
+	if nets := s.Spec.DataPlane.Networks; len(nets) > 0 {
+		allowed := map[string]bool{}
+		for _, n := range nets {
+			allowed[n] = true
+		}
+		for _, e := range strings.Split(ann[upfNetworksAnnotation], ",") {
+			netName, _, ok := strings.Cut(strings.TrimSpace(e), "@")
+			if ok && !allowed[netName] {
+				errs = append(errs, field.NotSupported(fp.Key(upfNetworksAnnotation), netName, nets))
+			}
+		}
+	}
+	return errs
+}
+
+// sameSliceValue – porównanie bez wielkości liter (SD) i z normalizacją CIDR
+func sameSliceValue(key, got, want string) bool {
+	if key == uePoolCidrAnnotation || key == n6CidrAnnotation {
+		_, g, err1 := net.ParseCIDR(got)
+		_, w, err2 := net.ParseCIDR(want)
+		if err1 == nil && err2 == nil {
+			return g.String() == w.String()
+		}
+	}
+	return strings.EqualFold(got, want)
+}
+
+// --------- KONTROLER STATUSU ---------
+
+// sliceStatusController – status NetworkSlice: Pody UPF/SMF wskazujące slice
+// (Ready = podpięte, z cache Podów); przeliczany po zmianie slice'a albo Poda
+// z anotacją network-slice i co SLICE_STATUS_RESYNC
+type sliceStatusController struct {
+	client  dynamic.Interface
+	lister  cache.GenericLister
+	trigger chan struct{}
+}
+
+// startNetworkSlices – informer NetworkSlice (sliceLister) + kontroler statusu
+// na informerze Podów z fabryki z main(); błąd, gdy CRD nie jest zainstalowane
+func startNetworkSlices(clientset kubernetes.Interface, client dynamic.Interface, pods cache.SharedIndexInformer, stop <-chan struct{}) error {
+	gv := networkSliceGVR.GroupVersion().String()
+	if _, err := clientset.Discovery().ServerResourcesForGroupVersion(gv); err != nil {
+		return fmt.Errorf("discovery %s: %w", gv, err)
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 10*time.Minute)
+	informer := factory.ForResource(networkSliceGVR)
+
+	c := &sliceStatusController{
+		client:  client,
+		lister:  informer.Lister(),
+		trigger: make(chan struct{}, 1),
+	}
+	kick := func() {
+		select {
+		case c.trigger <- struct{}{}:
+		default:
+		}
+	}
+	if _, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { kick() },
+		UpdateFunc: func(_, _ interface{}) { kick() },
+	}); err != nil {
+		return fmt.Errorf("network slice event handler: %w", err)
+	}
+	// Pody: tylko te, które wskazują (albo wskazywały) slice
+	slicePod := func(obj interface{}) bool {
+		p, ok := obj.(*corev1.Pod)
+		return !ok || p.Annotations[networkSliceAnnotation] != ""
+	}
+	if _, err := pods.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if slicePod(obj) {
+				kick()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if slicePod(oldObj) || slicePod(newObj) {
+				kick()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if slicePod(obj) {
+				kick()
+			}
+		},
+	}); err != nil {
+		return fmt.Errorf("network slice pod event handler: %w", err)
+	}
+
+	factory.Start(stop)
+	for typ, ok := range factory.WaitForCacheSync(stop) {
+		if !ok {
+			return fmt.Errorf("informer cache sync failed for %v", typ)
+		}
+	}
+	sliceLister = c.lister
+	go c.run(stop)
+	return nil
+}
+
+func (c *sliceStatusController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(sliceStatusResync)
+	defer ticker.Stop()
+	for {
+		c.syncAll()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+			// seria zdarzeń (rollout, zmiany gotowości) -> jeden przebieg
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func (c *sliceStatusController) syncAll() {
+	objs, err := c.lister.List(nil)
+	if err != nil {
+		log.Printf("network slices: list: %v", err)
+		return
+	}
+	for _, o := range objs {
+		u, ok := o.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if err := c.sync(u); err != nil {
+			log.Printf("network slice %s/%s: %v", u.GetNamespace(), u.GetName(), err)
+		}
+	}
+}
+
+func (c *sliceStatusController) sync(u *unstructured.Unstructured) error {
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+
+	status, err := c.status(u)
+	if err != nil {
+		return err
+	}
+	want, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+	if cur, _, _ := unstructured.NestedMap(u.Object, "status"); reflect.DeepEqual(cur, want) {
+		return nil
+	}
+
+	obj := u.DeepCopy()
+	if err := unstructured.SetNestedMap(obj.Object, want, "status"); err != nil {
+		return err
+	}
+	_, err = c.client.Resource(networkSliceGVR).Namespace(obj.GetNamespace()).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		// nowsza wersja przyjdzie z informera
+		return nil
+	}
+	return err
+}
+
+// status – gotowe Pody UPF/SMF z anotacją network-slice wskazującą slice
+func (c *sliceStatusController) status(u *unstructured.Unstructured) (*networkSliceStatus, error) {
+	pods, err := podLister.Pods(u.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	st := &networkSliceStatus{ObservedGeneration: u.GetGeneration()}
+	for _, p := range pods {
+		if strings.TrimSpace(p.Annotations[networkSliceAnnotation]) != u.GetName() || !podReady(p) {
+			continue
+		}
+		switch {
+		case isUpfPodTemplate(&corev1.PodTemplateSpec{ObjectMeta: p.ObjectMeta, Spec: p.Spec}):
+			st.UPFs = append(st.UPFs, p.Name)
+		case p.Labels[nfLabelKey] == "smf":
+			st.SMFs = append(st.SMFs, p.Name)
+		}
+	}
+	sort.Strings(st.UPFs)
+	sort.Strings(st.SMFs)
+	st.BoundUPFs, st.BoundSMFs = int64(len(st.UPFs)), int64(len(st.SMFs))
+
+	switch {
+	case st.BoundUPFs > 0 && st.BoundSMFs > 0:
+		st.Phase = "Ready"
+	case st.BoundUPFs > 0 || st.BoundSMFs > 0:
+		st.Phase = "Partial"
+	default:
+		st.Phase = "Pending"
+	}
+	return st, nil
+}
+
+func podReady(p *corev1.Pod) bool {
+	if p.Status.Phase != corev1.PodRunning || p.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ConfigMapy z konfiguracją NF free5gc (amfcfg.yaml, smfcfg.yaml, ...)
+// renderowane przez Helm. Większość awarii to zepsuty YAML albo literówka
+// w PLMN/S-NSSAI/adresach, a nie spec Poda. YAML parsujemy do yaml.Node,
+// żeby każdy błąd wskazywał linię w pliku konfiguracyjnym.
+
+// nfConfigValidators – znane klucze data ConfigMapy -> walidator schematu
+var nfConfigValidators = map[string]func(root cfgNode) field.ErrorList{
+	"amfcfg.yaml": validateAmfConfig,
+	"smfcfg.yaml": validateSmfConfig,
+	"upfcfg.yaml": validateUpfConfig,
+	"nrfcfg.yaml": validateNrfConfig,
+}
+
+var (
+	mccRegex = regexp.MustCompile(`^[0-9]{3}$`)
+	mncRegex = regexp.MustCompile(`^[0-9]{2,3}$`)
+	sdRegex  = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+	// TAC (24 bity) i AMF ID (region 8 + set 10 + pointer 6 bitów) – 6 cyfr hex
+	tacRegex   = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+	amfIdRegex = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+	// DNN (TS 23.003 §9.1): etykiety [A-Za-z0-9-] rozdzielone kropkami
+	dnnRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+)
+
+// cfgNode – węzeł YAML + ścieżka w ConfigMapie (data[amfcfg.yaml].configuration...).
+// Brakujący klucz to węzeł z n == nil i linią rodzica.
+type cfgNode struct {
+	n    *yaml.Node
+	fp   *field.Path
+	line int
+	// key – klucz mapy (tylko dla węzłów z entries())
+	key string
+}
+
+func (c cfgNode) exists() bool {
+	return c.n != nil && c.n.Tag != "!!null"
+}
+
+func (c cfgNode) value() string {
+	if c.n == nil {
+		return ""
+	}
+	return c.n.Value
+}
+
+// get – wartość klucza mapy
+func (c cfgNode) get(key string) cfgNode {
+	child := cfgNode{fp: c.fp.Child(key), line: c.line}
+	if c.n == nil || c.n.Kind != yaml.MappingNode {
+		return child
+	}
+	for i := 0; i+1 < len(c.n.Content); i += 2 {
+		if c.n.Content[i].Value == key {
+			child.n, child.line = c.n.Content[i+1], c.n.Content[i+1].Line
+			break
+		}
+	}
+	return child
+}
+
+// items – elementy sekwencji
+func (c cfgNode) items() []cfgNode {
+	if c.n == nil || c.n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	out := make([]cfgNode, 0, len(c.n.Content))
+	for i, n := range c.n.Content {
+		out = append(out, cfgNode{n: n, fp: c.fp.Index(i), line: n.Line})
+	}
+	return out
+}
+
+// entries – pary klucz/wartość mapy (w kolejności z pliku)
+func (c cfgNode) entries() []cfgNode {
+	if c.n == nil || c.n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var out []cfgNode
+	for i := 0; i+1 < len(c.n.Content); i += 2 {
+		v := c.n.Content[i+1]
+		key := c.n.Content[i].Value
+		out = append(out, cfgNode{n: v, fp: c.fp.Key(key), line: v.Line, key: key})
+	}
+	return out
+}
+
+func (c cfgNode) msg(format string, args ...interface{}) string {
+	return fmt.Sprintf("line %d: ", c.line) + fmt.Sprintf(format, args...)
+}
+
+// cfgChecker – zbiera błędy walidacji jednego pliku konfiguracyjnego
+type cfgChecker struct {
+	errs field.ErrorList
+}
+
+func (k *cfgChecker) invalid(c cfgNode, format string, args ...interface{}) {
+	k.errs = append(k.errs, field.Invalid(c.fp, c.value(), c.msg(format, args...)))
+}
+
+// required – klucz musi istnieć i nie być pusty
+func (k *cfgChecker) required(c cfgNode) bool {
+	if !c.exists() || (c.n.Kind == yaml.ScalarNode && strings.TrimSpace(c.n.Value) == "") {
+		k.errs = append(k.errs, field.Required(c.fp, c.msg("required")))
+		return false
+	}
+	return true
+}
+
+// list – wymagana, niepusta sekwencja
+func (k *cfgChecker) list(c cfgNode) []cfgNode {
+	if !k.required(c) {
+		return nil
+	}
+	if c.n.Kind != yaml.SequenceNode {
+		k.invalid(c, "expected a list")
+		return nil
+	}
+	if len(c.n.Content) == 0 {
+		k.errs = append(k.errs, field.Required(c.fp, c.msg("must not be empty")))
+	}
+	return c.items()
+}
+
+func (k *cfgChecker) ip(c cfgNode) {
+	if k.required(c) && net.ParseIP(strings.TrimSpace(c.value())) == nil {
+		k.invalid(c, "not a valid IP address")
+	}
+}
+
+// host – IP albo nazwa DNS (np. nazwa Service'u renderowana przez Helm)
+func (k *cfgChecker) host(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	v := strings.TrimSpace(c.value())
+	if net.ParseIP(v) != nil {
+		return
+	}
+	if msgs := validation.IsDNS1123Subdomain(v); len(msgs) > 0 {
+		k.invalid(c, "not a valid IP address or DNS name")
+	}
+}
+
+func (k *cfgChecker) port(c cfgNode) {
+	if !c.exists() {
+		return
+	}
+	if p, err := strconv.Atoi(c.value()); err != nil || p <= 0 || p > 65535 {
+		k.invalid(c, "not a valid port")
+	}
+}
+
+// plmn – MCC 3 cyfry, MNC 2–3 cyfry (surowy tekst z YAML, więc "01" zostaje "01")
+func (k *cfgChecker) plmn(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	if mcc := c.get("mcc"); k.required(mcc) && !mccRegex.MatchString(mcc.value()) {
+		k.invalid(mcc, "MCC must be exactly 3 digits")
+	}
+	if mnc := c.get("mnc"); k.required(mnc) && !mncRegex.MatchString(mnc.value()) {
+		k.invalid(mnc, "MNC must be 2 or 3 digits")
+	}
+}
+
+func (c cfgNode) plmnKey() string {
+	return c.get("mcc").value() + "-" + c.get("mnc").value()
+}
+
+// tac – 24-bitowy TAC jako 6 cyfr hex ("000001")
+func (k *cfgChecker) tac(c cfgNode) {
+	if k.required(c) && !tacRegex.MatchString(c.value()) {
+		k.invalid(c, "TAC must be 6 hex digits (24 bits)")
+	}
+}
+
+// amfID – AMF Region ID (8 bitów), AMF Set ID (10) i AMF Pointer (6) jako 6 cyfr hex;
+// 24 bity z definicji mieszczą się w zakresach pól GUAMI
+func (k *cfgChecker) amfID(c cfgNode) {
+	if k.required(c) && !amfIdRegex.MatchString(c.value()) {
+		k.invalid(c, "amfId must be 6 hex digits (region 0-255, set 0-1023, pointer 0-63)")
+	}
+}
+
+// snssai – SST 0–255, opcjonalny SD jako 6 cyfr hex
+func (k *cfgChecker) snssai(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	if sst := c.get("sst"); k.required(sst) {
+		if v, err := strconv.Atoi(sst.value()); err != nil || v < 0 || v > 255 {
+			k.invalid(sst, "SST must be an integer 0-255")
+		}
+	}
+	if sd := c.get("sd"); sd.exists() && !sdRegex.MatchString(sd.value()) {
+		k.invalid(sd, "SD must be 6 hex digits")
+	}
+}
+
+func (k *cfgChecker) dnn(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	if v := c.value(); len(v) > 100 || !dnnRegex.MatchString(v) {
+		k.invalid(c, "not a valid DNN (labels of letters, digits and '-', separated by '.')")
+	}
+}
+
+// uri – URI SBI (np. nrfUri): http/https z hostem
+func (k *cfgChecker) uri(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(c.value()))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		k.invalid(c, "must be an http:// or https:// URI with a host")
+		return
+	}
+	if p := u.Port(); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n <= 0 || n > 65535 {
+			k.invalid(c, "invalid port in URI")
+		}
+	}
+}
+
+// sbi – blok sbi: scheme, registerIPv4 (IP/DNS), bindingIPv4 (IP), port
+func (k *cfgChecker) sbi(c cfgNode) {
+	if !k.required(c) {
+		return
+	}
+	if s := c.get("scheme"); s.exists() && s.value() != "http" && s.value() != "https" {
+		k.invalid(s, "scheme must be http or https")
+	}
+	if r := c.get("registerIPv4"); r.exists() {
+		k.host(r)
+	}
+	if b := c.get("bindingIPv4"); b.exists() {
+		k.ip(b)
+	}
+	k.port(c.get("port"))
+}
+
+// cfgPool – pula UE już widziana w pliku (wykrywanie nakładających się pul)
+type cfgPool struct {
+	node cfgNode
+	net  *net.IPNet
+}
+
+// cidr – poprawny CIDR puli UE, bez nakładania się z wcześniejszymi w pliku
+func (k *cfgChecker) cidr(c cfgNode, seen *[]cfgPool) {
+	if !k.required(c) {
+		return
+	}
+	_, n, err := net.ParseCIDR(strings.TrimSpace(c.value()))
+	if err != nil {
+		k.invalid(c, "not a valid CIDR")
+		return
+	}
+	for _, p := range *seen {
+		if p.net.Contains(n.IP) || n.Contains(p.net.IP) {
+			k.invalid(c, "UE pool overlaps %s (line %d)", p.net, p.node.line)
+			return
+		}
+	}
+	*seen = append(*seen, cfgPool{node: c, net: n})
+}
+
+// --------- SCHEMATY NF ---------
+
+func validateAmfConfig(root cfgNode) field.ErrorList {
+	k := &cfgChecker{}
+	cfg := root.get("configuration")
+	if !k.required(cfg) {
+		return k.errs
+	}
+
+	for _, ip := range k.list(cfg.get("ngapIpList")) {
+		k.ip(ip)
+	}
+	k.port(cfg.get("ngapPort"))
+	k.sbi(cfg.get("sbi"))
+	k.uri(cfg.get("nrfUri"))
+
+	// GUAMI i TAI muszą dotyczyć PLMN-ów z plmnSupportList
+	served := map[string]bool{}
+	for _, p := range k.list(cfg.get("plmnSupportList")) {
+		k.plmn(p.get("plmnId"))
+		served[p.get("plmnId").plmnKey()] = true
+		for _, s := range k.list(p.get("snssaiList")) {
+			k.snssai(s)
+		}
+	}
+	inSupport := func(plmn cfgNode) {
+		if plmn.exists() && len(served) > 0 && !served[plmn.plmnKey()] {
+			k.invalid(plmn, "PLMN %s is not in plmnSupportList", plmn.plmnKey())
+		}
+	}
+	guamis := map[string]int{}
+	for _, g := range cfg.get("servedGuamiList").items() {
+		k.plmn(g.get("plmnId"))
+		inSupport(g.get("plmnId"))
+		k.amfID(g.get("amfId"))
+		key := g.get("plmnId").plmnKey() + "/" + strings.ToLower(g.get("amfId").value())
+		if line, dup := guamis[key]; dup {
+			k.invalid(g.get("amfId"), "duplicate GUAMI %s (line %d)", key, line)
+		}
+		guamis[key] = g.line
+	}
+	tais := map[string]int{}
+	for _, t := range cfg.get("supportTaiList").items() {
+		k.plmn(t.get("plmnId"))
+		inSupport(t.get("plmnId"))
+		k.tac(t.get("tac"))
+		key := t.get("plmnId").plmnKey() + "/" + strings.ToLower(t.get("tac").value())
+		if line, dup := tais[key]; dup {
+			k.invalid(t.get("tac"), "duplicate TAI %s (line %d)", key, line)
+		}
+		tais[key] = t.line
+	}
+	for _, d := range k.list(cfg.get("supportDnnList")) {
+		k.dnn(d)
+	}
+	return k.errs
+}
+
+func validateSmfConfig(root cfgNode) field.ErrorList {
+	k := &cfgChecker{}
+	cfg := root.get("configuration")
+	if !k.required(cfg) {
+		return k.errs
+	}
+
+	k.sbi(cfg.get("sbi"))
+	k.uri(cfg.get("nrfUri"))
+	for _, p := range cfg.get("plmnList").items() {
+		k.plmn(p)
+	}
+	for _, s := range k.list(cfg.get("snssaiInfos")) {
+		k.snssai(s.get("sNssai"))
+		for _, d := range k.list(s.get("dnnInfos")) {
+			k.dnn(d.get("dnn"))
+		}
+	}
+
+	// pfcp: nodeID + listenAddr/externalAddr (free5gc >= 3.3) albo addr (starsze)
+	if pfcp := cfg.get("pfcp"); k.required(pfcp) {
+		if pfcp.get("nodeID").exists() {
+			k.host(pfcp.get("nodeID"))
+		} else {
+			k.ip(pfcp.get("addr"))
+		}
+		for _, key := range []string{"addr", "listenAddr", "externalAddr"} {
+			if a := pfcp.get(key); a.exists() && pfcp.get("nodeID").exists() {
+				k.ip(a)
+			}
+		}
+	}
+
+	up := cfg.get("userplaneInformation")
+	if !k.required(up) {
+		return k.errs
+	}
+	nodes := up.get("upNodes")
+	names := map[string]bool{}
+	var pools []cfgPool
+	if k.required(nodes) {
+		for _, n := range nodes.entries() {
+			names[n.key] = true
+			typ := n.get("type")
+			if !k.required(typ) {
+				continue
+			}
+			switch typ.value() {
+			case "AN":
+			case "UPF":
+				k.host(n.get("nodeID"))
+				if a := n.get("addr"); a.exists() {
+					k.ip(a)
+				}
+				for _, s := range n.get("sNssaiUpfInfos").items() {
+					k.snssai(s.get("sNssai"))
+					for _, d := range s.get("dnnUpfInfoList").items() {
+						k.dnn(d.get("dnn"))
+						for _, p := range d.get("pools").items() {
+							k.cidr(p.get("cidr"), &pools)
+						}
+					}
+				}
+				for _, i := range n.get("interfaces").items() {
+					if t := i.get("interfaceType"); k.required(t) && t.value() != "N3" && t.value() != "N9" {
+						k.invalid(t, "interfaceType must be N3 or N9")
+					}
+					for _, e := range i.get("endpoints").items() {
+						k.host(e)
+					}
+				}
+			default:
+				k.invalid(typ, "upNode type must be AN or UPF")
+			}
+		}
+	}
+	for _, l := range up.get("links").items() {
+		for _, end := range []string{"A", "B"} {
+			if e := l.get(end); k.required(e) && !names[e.value()] {
+				k.invalid(e, "link endpoint is not defined in upNodes")
+			}
+		}
+	}
+	return k.errs
+}
+
+func validateUpfConfig(root cfgNode) field.ErrorList {
+	k := &cfgChecker{}
+
+	if pfcp := root.get("pfcp"); k.required(pfcp) {
+		k.ip(pfcp.get("addr"))
+		k.host(pfcp.get("nodeID"))
+	}
+	if gtpu := root.get("gtpu"); k.required(gtpu) {
+		for _, i := range k.list(gtpu.get("ifList")) {
+			k.ip(i.get("addr"))
+			if t := i.get("type"); k.required(t) && t.value() != "N3" && t.value() != "N9" {
+				k.invalid(t, "type must be N3 or N9")
+			}
+		}
+	}
+	var pools []cfgPool
+	for _, d := range k.list(root.get("dnnList")) {
+		k.dnn(d.get("dnn"))
+		k.cidr(d.get("cidr"), &pools)
+	}
+	return k.errs
+}
+
+func validateNrfConfig(root cfgNode) field.ErrorList {
+	k := &cfgChecker{}
+	cfg := root.get("configuration")
+	if !k.required(cfg) {
+		return k.errs
+	}
+	k.sbi(cfg.get("sbi"))
+	k.plmn(cfg.get("DefaultPlmnId"))
+	if m := cfg.get("MongoDBUrl"); m.exists() && !strings.HasPrefix(m.value(), "mongodb://") && !strings.HasPrefix(m.value(), "mongodb+srv://") {
+		k.invalid(m, "must be a mongodb:// URI")
+	}
+	return k.errs
+}
+
+// --------- VALIDATING ---------
+
+// parseNFConfig – YAML jednego klucza data -> korzeń dokumentu
+func parseNFConfig(key, data string) (cfgNode, *field.Error) {
+	fp := field.NewPath("data").Key(key)
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return cfgNode{}, field.Invalid(fp, key, err.Error())
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return cfgNode{}, field.Invalid(fp, key, "line 1: expected a YAML mapping")
+	}
+	return cfgNode{n: doc.Content[0], fp: fp, line: doc.Content[0].Line}, nil
+}
+
+// validateNFConfigMap – wszystkie znane klucze *cfg.yaml w ConfigMapie
+func validateNFConfigMap(cm *corev1.ConfigMap) field.ErrorList {
+	var keys []string
+	for key := range cm.Data {
+		if _, ok := nfConfigValidators[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var errs field.ErrorList
+	for _, key := range keys {
+		root, err := parseNFConfig(key, cm.Data[key])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, nfConfigValidators[key](root)...)
+	}
+	return errs
+}
+
+// validateConfigMap – błędy (odmowa) i ostrzeżenia (AdmissionResponse.Warnings,
+// w audycie – naruszenia)
+func validateConfigMap(raw []byte, namespace string, clientset kubernetes.Interface) (field.ErrorList, field.ErrorList) {
+	cm := &corev1.ConfigMap{}
+	if _, _, err := deserializer.Decode(raw, nil, cm); err != nil {
+		return field.ErrorList{
+			field.Invalid(field.NewPath("kind"), "ConfigMap", fmt.Sprintf("decode configmap: %v", err)),
+		}, nil
+	}
+
+	shouldHandle, nsObj, err := shouldHandleNamespace(context.Background(), clientset, namespace)
+	if err != nil {
+		return field.ErrorList{
+			field.Invalid(field.NewPath("metadata", "namespace"), namespace, err.Error()),
+		}, nil
+	}
+	if !shouldHandle || !isFree5gcWorkload(cm.ObjectMeta, nsObj.Name) {
+		return nil, nil
+	}
+	if errs := validateNFConfigMap(cm); len(errs) > 0 {
+		return errs, nil
+	}
+	// smfcfg.yaml: upNodes względem UPF-ów w namespace (slicetopology.go),
+	// amfcfg.yaml: plmnSupportList względem innych AMF-ów (amfplmn.go)
+	errs, warns := validateSmfTopology(cm, namespace)
+	return append(errs, validateAmfConfigPlmnConsistency(cm, namespace)...), warns
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Zmienne środowiskowe NF: URI SBI NRF-a (z Service'u NRF w namespace),
+// własny adres rejestracji (POD_IP z downward API) i parametry slice'a
+// z anotacji. Zmienne ustawione przez użytkownika (Helm) nie są ruszane.
+
+var (
+	// NRF_ENV_INJECT=false – bez dopisywania zmiennych NRF/POD_IP/slice
+	nrfEnvInject = getEnvBool("NRF_ENV_INJECT", true)
+	// NRF_SERVICE – nazwa Service'u NRF; puste = wykrywanie (label/selektor nf=nrf, nazwa *nrf*)
+	nrfServiceName = getEnv("NRF_SERVICE", "")
+	// NRF_SCHEME – schemat SBI NRF-a
+	nrfScheme = getEnv("NRF_SCHEME", "http")
+	// CLUSTER_DOMAIN – domena klastra do NRF_URI; puste = <svc>.<ns>.svc (search path resolv.conf)
+	clusterDomain = strings.Trim(getEnv("CLUSTER_DOMAIN", ""), ".")
+
+	// 5g.kkarczmarek.dev/nrf-env=false – wyłącza wstrzykiwanie dla workloadu
+	nrfEnvAnnotation = "5g.kkarczmarek.dev/nrf-env"
+)
+
+// nfSliceEnvKeys – anotacja -> sufiks zmiennej (prefiks = typ NF, jak UPF_* w sliceEnvVars)
+var nfSliceEnvKeys = []struct{ Annotation, Suffix string }{
+	{sliceIdAnnotation, "SLICE_ID"},
+	{sstAnnotation, "SST"},
+	{sdAnnotation, "SD"},
+	{dnnAnnotation, "DNN"},
+	{uePoolCidrAnnotation, "UE_POOL_CIDR"},
+	{n6CidrAnnotation, "N6_CIDR"},
+}
+
+// nrfServiceScore – jak pewnie Service jest NRF-em (0 = nie jest)
+func nrfServiceScore(svc *corev1.Service) int {
+	switch {
+	case nrfServiceName != "":
+		if svc.Name == nrfServiceName {
+			return 3
+		}
+		return 0
+	case svc.Labels[nfLabelKey] == "nrf":
+		return 3
+	case svc.Spec.Selector[nfLabelKey] == "nrf":
+		return 2
+	case strings.Contains(svc.Name, "nrf"):
+		return 1
+	}
+	return 0
+}
+
+// nrfSBIPort – port nazwany sbi, port SBI_PORT albo jedyny port Service'u
+func nrfSBIPort(svc *corev1.Service) int32 {
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "sbi" {
+			return p.Port
+		}
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Port == sbiPort {
+			return p.Port
+		}
+	}
+	if len(svc.Spec.Ports) == 1 {
+		return svc.Spec.Ports[0].Port
+	}
+	return 0
+}
+
+// discoverNrfURI – URI SBI NRF-a w namespace (cache informera Service'ów,
+// bez niego zapytanie do API); "" gdy NRF nie znaleziony
+func discoverNrfURI(clientset kubernetes.Interface, namespace string) (string, error) {
+	var svcs []*corev1.Service
+	if serviceIndexer != nil {
+		for _, o := range serviceIndexer.List() {
+			if svc, ok := o.(*corev1.Service); ok && svc.Namespace == namespace {
+				svcs = append(svcs, svc)
+			}
+		}
+	} else {
+		ctx, cancel := ctxWithTimeout()
+		defer cancel()
+		list, err := clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return "", fmt.Errorf("list services: %w", err)
+		}
+		for i := range list.Items {
+			svcs = append(svcs, &list.Items[i])
+		}
+	}
+
+	// najlepszy wynik, przy remisie pierwszy alfabetycznie
+	sort.Slice(svcs, func(i, j int) bool { return svcs[i].Name < svcs[j].Name })
+	var best *corev1.Service
+	bestScore := 0
+	for _, svc := range svcs {
+		if s := nrfServiceScore(svc); s > bestScore && nrfSBIPort(svc) != 0 {
+			best, bestScore = svc, s
+		}
+	}
+	if best == nil {
+		return "", nil
+	}
+	host := best.Name + "." + namespace + ".svc"
+	if clusterDomain != "" {
+		host += "." + clusterDomain
+	}
+	return fmt.Sprintf("%s://%s:%d", nrfScheme, host, nrfSBIPort(best)), nil
+}
+
+func fieldRefEnv(name, path string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name:      name,
+		ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: path}},
+	}
+}
+
+// nfEnvVars – zmienne dla głównego kontenera NF (nrfURI == "" – bez NRF_URI)
+func nfEnvVars(nf, nrfURI string, ann map[string]string) []corev1.EnvVar {
+	envs := []corev1.EnvVar{
+		{Name: "NF_TYPE", Value: strings.ToUpper(nf)},
+		fieldRefEnv("POD_IP", "status.podIP"),
+		fieldRefEnv("POD_NAME", "metadata.name"),
+		fieldRefEnv("POD_NAMESPACE", "metadata.namespace"),
+	}
+	if nrfURI != "" && nf != "nrf" {
+		envs = append(envs, corev1.EnvVar{Name: "NRF_URI", Value: nrfURI})
+	}
+	prefix := strings.ToUpper(nf) + "_"
+	for _, k := range nfSliceEnvKeys {
+		if v := strings.TrimSpace(ann[k.Annotation]); v != "" {
+			envs = append(envs, corev1.EnvVar{Name: prefix + k.Suffix, Value: v})
+		}
+	}
+	return envs
+}
+
+// ensureNFEnv – zmienne NF w kontenerze o nazwie NF (albo pierwszym);
+// zmienna o tej samej nazwie ustawiona przez użytkownika wygrywa
+func ensureNFEnv(clientset kubernetes.Interface, namespace string, meta *metav1.ObjectMeta, spec *corev1.PodSpec, nf string) {
+	if !nrfEnvInject || nf == "" || len(spec.Containers) == 0 ||
+		strings.EqualFold(strings.TrimSpace(meta.Annotations[nrfEnvAnnotation]), "false") {
+		return
+	}
+
+	nrfURI, err := discoverNrfURI(clientset, namespace)
+	if err != nil {
+		log.Printf("nrf discovery in %s: %v", namespace, err)
+	}
+
+	c := &spec.Containers[mainContainerIndex(meta, spec.Containers)]
+	set := map[string]bool{}
+	for _, e := range c.Env {
+		set[e.Name] = true
+	}
+	for _, e := range nfEnvVars(nf, nrfURI, meta.Annotations) {
+		if !set[e.Name] {
+			c.Env = append(c.Env, e)
+		}
+	}
+}
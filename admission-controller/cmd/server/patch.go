@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON Patch operation (RFC 6902)
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON – "value" musi być obecne dla add/replace także wtedy, gdy jest
+// zerowe (false, 0, ""); omitempty by je zgubił i patch byłby niepoprawny.
+func (p patchOp) MarshalJSON() ([]byte, error) {
+	if p.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{p.Op, p.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{p.Op, p.Path, p.Value})
+}
+
+// buildPatch liczy patch jako różnicę między oryginałem a zmutowaną kopią.
+// Mutatory zmieniają obiekt w miejscu, a tutaj powstaje minimalny,
+// deterministyczny patch: klucze map w kolejności alfabetycznej, nowe
+// elementy tablic dopisywane rosnąco po indeksie, usuwane od końca.
+// Ponowne wywołanie na już zmutowanym obiekcie daje pusty patch, więc
+// reinvocationPolicy: IfNeeded nie produkuje konfliktowych operacji.
+//
+// raw to dokument z AdmissionRequest – typowany obiekt serializuje się
+// inaczej (np. "resources": {} zamiast braku pola), więc ścieżki operacji
+// są na koniec "kotwiczone" w raw: brakujący rodzic jest dodawany w całości.
+func buildPatch(raw []byte, original, mutated interface{}) ([]patchOp, error) {
+	a, err := toGeneric(original)
+	if err != nil {
+		return nil, fmt.Errorf("marshal original: %w", err)
+	}
+	b, err := toGeneric(mutated)
+	if err != nil {
+		return nil, fmt.Errorf("marshal mutated: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal raw object: %w", err)
+	}
+
+	var ops []patchOp
+	diffValues("", a, b, &ops)
+	return anchorOps(doc, b, ops), nil
+}
+
+// marshalPatch – buildPatch + serializacja; nil gdy nic się nie zmieniło
+func marshalPatch(raw []byte, original, mutated interface{}) ([]byte, error) {
+	ops, err := buildPatch(raw, original, mutated)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(ops)
+}
+
+func toGeneric(obj interface{}) (interface{}, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func diffValues(path string, a, b interface{}, ops *[]patchOp) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			diffMaps(path, av, bv, ops)
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			diffArrays(path, av, bv, ops)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, patchOp{Op: "replace", Path: path, Value: b})
+	}
+}
+
+func diffMaps(path string, a, b map[string]interface{}, ops *[]patchOp) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapeJSONPointer(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case inA && !inB:
+			*ops = append(*ops, patchOp{Op: "remove", Path: p})
+		case !inA && inB:
+			*ops = append(*ops, patchOp{Op: "add", Path: p, Value: bv})
+		default:
+			diffValues(p, av, bv, ops)
+		}
+	}
+}
+
+func diffArrays(path string, a, b []interface{}, ops *[]patchOp) {
+	common := len(a)
+	if len(b) < common {
+		common = len(b)
+	}
+	for i := 0; i < common; i++ {
+		diffValues(path+"/"+strconv.Itoa(i), a[i], b[i], ops)
+	}
+	// nowe elementy: indeks == aktualna długość, czyli dopisanie na koniec
+	for i := common; i < len(b); i++ {
+		*ops = append(*ops, patchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+	}
+	// usuwanie od końca, żeby wcześniejsze indeksy pozostały ważne
+	for i := len(a) - 1; i >= common; i-- {
+		*ops = append(*ops, patchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+}
+
+// anchorOps dopasowuje operacje do faktycznego dokumentu: jeśli w doc brakuje
+// rodzica ścieżki, zamiast operacji na liściu dodajemy najwyższego brakującego
+// przodka z wartością z mutated (a kolejne operacje pod nim pomijamy).
+func anchorOps(doc, mutated interface{}, ops []patchOp) []patchOp {
+	var (
+		out   []patchOp
+		added []string
+	)
+
+	for _, op := range ops {
+		if underAny(op.Path, added) {
+			continue
+		}
+		segs := splitPointer(op.Path)
+		missing := firstMissing(doc, segs)
+
+		switch {
+		case missing == len(segs):
+			out = append(out, op)
+		case op.Op == "remove":
+			// nie ma czego usuwać
+		case missing == len(segs)-1:
+			op.Op = "add"
+			out = append(out, op)
+		default:
+			p := joinPointer(segs[:missing+1])
+			out = append(out, patchOp{Op: "add", Path: p, Value: lookupPointer(mutated, segs[:missing+1])})
+			added = append(added, p)
+		}
+	}
+
+	return out
+}
+
+func underAny(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, s := range segs {
+		s = strings.ReplaceAll(s, "~1", "/")
+		segs[i] = strings.ReplaceAll(s, "~0", "~")
+	}
+	return segs
+}
+
+func joinPointer(segs []string) string {
+	var sb strings.Builder
+	for _, s := range segs {
+		sb.WriteString("/")
+		sb.WriteString(escapeJSONPointer(s))
+	}
+	return sb.String()
+}
+
+// firstMissing – indeks pierwszego segmentu, którego nie ma w doc (len(segs) gdy cała ścieżka istnieje)
+func firstMissing(doc interface{}, segs []string) int {
+	cur := doc
+	for i, s := range segs {
+		next, ok := child(cur, s)
+		if !ok {
+			return i
+		}
+		cur = next
+	}
+	return len(segs)
+}
+
+func lookupPointer(doc interface{}, segs []string) interface{} {
+	cur := doc
+	for _, s := range segs {
+		next, ok := child(cur, s)
+		if !ok {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+func child(v interface{}, seg string) (interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		// "labels": null traktujemy jak brak pola – do null-a nie da się nic dodać
+		c, ok := t[seg]
+		return c, ok && c != nil
+	case []interface{}:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i >= len(t) {
+			return nil, false
+		}
+		return t[i], true
+	}
+	return nil, false
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tryb -mode=pcap-server: mały serwer HTTP uruchamiany jako sidecar obok
+// tcpdumpa. Listuje i strumieniuje rotowane pliki pcap z katalogu /data:
+//
+//	GET /pcaps          – lista plików (JSON)
+//	GET /pcaps/<ścieżka> – zawartość pliku (application/vnd.tcpdump.pcap)
+//
+// Domyślnie słucha na 127.0.0.1, więc dostęp jest tylko przez
+// `kubectl port-forward` (czyli z uprawnieniem pods/portforward).
+
+// trace.pcap, trace.pcap0, trace.pcap1, ... (rotacja tcpdump -C/-W)
+var pcapFileRegex = regexp.MustCompile(`^` + regexp.QuoteMeta(tcpdumpFileName) + `[0-9]*$`)
+
+// pcapFile – wpis listy; Path jest względna wobec katalogu danych
+// (w trybie PVC: <pod>/trace.pcapN)
+type pcapFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+type pcapServer struct {
+	dir string
+}
+
+func runPcapServer(addr, dir string) error {
+	s := &pcapServer{dir: dir}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pcaps", s.handleList)
+	mux.HandleFunc("/pcaps/", s.handleFile)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("starting pcap server on %s (dir %s)", addr, dir)
+	return srv.ListenAndServe()
+}
+
+// list – pliki pcap w katalogu i jeden poziom niżej (podkatalogi per Pod na PVC)
+func (s *pcapServer) list() ([]pcapFile, error) {
+	var files []pcapFile
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && strings.Count(rel, string(filepath.Separator)) > 0 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !pcapFileRegex.MatchString(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, pcapFile{
+			Path:     filepath.ToSlash(rel),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC(),
+		})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+func (s *pcapServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	files, err := s.list()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list pcaps: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = []pcapFile{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(files)
+}
+
+// handleFile – tylko pliki z listy (bez "..", linków i innych plików z wolumenu)
+func (s *pcapServer) handleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rel := strings.TrimPrefix(r.URL.Path, "/pcaps/")
+	if rel == "" || path.Clean(rel) != rel || strings.HasPrefix(rel, "/") || strings.Contains(rel, "..") {
+		http.Error(w, "invalid pcap path", http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(rel, "/")
+	if len(parts) > 2 || !pcapFileRegex.MatchString(parts[len(parts)-1]) {
+		http.Error(w, "invalid pcap path", http.StatusBadRequest)
+		return
+	}
+
+	full := filepath.Join(s.dir, filepath.FromSlash(rel))
+	info, err := os.Lstat(full)
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("open pcap: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.ReplaceAll(rel, "/", "_")))
+	// plik aktualnie zapisywany przez tcpdump rośnie – ServeContent wyśle
+	// stan z chwili Stat(), co daje poprawny (ucięty) pcap
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// prunePcapDirs – retencja na PVC (-mode=pcap-retention): każdy nowy Pod
+// (restart, rollout) zaczyna własny katalog <nazwa Poda>, a ring buffer
+// tcpdumpa (-C/-W) ogranicza tylko bieżący. Katalog, w którym od maxAge nic
+// się nie zmieniło, traci pliki pcap, a potem – jeśli jest pusty – sam znika;
+// katalog z innymi plikami zostaje.
+func prunePcapDirs(dir string, maxAge time.Duration, keep string) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	var errs []string
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == keep {
+			continue
+		}
+		sub := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		files, err := os.ReadDir(sub)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		var pcaps []string
+		fresh, other := false, false
+		for _, f := range files {
+			if !f.Type().IsRegular() || !pcapFileRegex.MatchString(f.Name()) {
+				other = true
+				continue
+			}
+			if fi, err := f.Info(); err == nil && fi.ModTime().After(cutoff) {
+				fresh = true
+				break
+			}
+			pcaps = append(pcaps, filepath.Join(sub, f.Name()))
+		}
+		if fresh {
+			continue
+		}
+		for _, p := range pcaps {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+		}
+		if other {
+			continue
+		}
+		if err := os.Remove(sub); err == nil {
+			removed++
+		} else if !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+	log.Printf("pcap retention: removed %d directories idle for more than %s from %s", removed, maxAge, dir)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Rozmieszczenie UPF: węzły z NIC-ami data plane (README: label upf=enabled),
+// tolerancje dla dedykowanych, ztaintowanych węzłów i rozrzucenie replik UPF
+// tego samego slice'a po węzłach. Jawne ustawienia użytkownika wygrywają.
+
+var (
+	// UPF_NODE_LABELS – wymagane labele węzła, np. "upf=enabled"; puste = bez node affinity
+	upfNodeLabels = getEnv("UPF_NODE_LABELS", "")
+	// UPF_NODE_TAINTS – tainty węzłów data plane "key=value:Effect" (albo "key:Effect")
+	upfNodeTaints = getEnv("UPF_NODE_TAINTS", "")
+	// UPF_SPREAD_POLICY – soft (preferowane), hard (wymagane) albo none
+	upfSpreadPolicy = getEnv("UPF_SPREAD_POLICY", "soft")
+	upfTopologyKey  = getEnv("UPF_TOPOLOGY_KEY", corev1.LabelHostname)
+
+	// 5g.kkarczmarek.dev/upf-placement=false – wyłączenie dla konkretnego obiektu
+	upfPlacementAnnotation = "5g.kkarczmarek.dev/upf-placement"
+)
+
+// parseNodeLabels – "k=v,k2=v2" -> pary w kolejności z konfiguracji
+func parseNodeLabels(raw string) ([][2]string, error) {
+	var out [][2]string
+	for _, e := range strings.Split(raw, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid node label %q, expected key=value", e)
+		}
+		out = append(out, [2]string{strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])})
+	}
+	return out, nil
+}
+
+// parseNodeTaints – "key=value:NoSchedule,key2:NoExecute" -> tolerancje
+func parseNodeTaints(raw string) ([]corev1.Toleration, error) {
+	var out []corev1.Toleration
+	for _, e := range strings.Split(raw, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		i := strings.LastIndex(e, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid taint %q, expected key[=value]:Effect", e)
+		}
+		effect := corev1.TaintEffect(e[i+1:])
+		switch effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("invalid taint effect %q in %q", effect, e)
+		}
+		tol := corev1.Toleration{Effect: effect, Operator: corev1.TolerationOpExists}
+		key := e[:i]
+		if kv := strings.SplitN(key, "=", 2); len(kv) == 2 {
+			tol.Key, tol.Operator, tol.Value = kv[0], corev1.TolerationOpEqual, kv[1]
+		} else {
+			tol.Key = key
+		}
+		out = append(out, tol)
+	}
+	return out, nil
+}
+
+// ensureUpfPlacement – node affinity, tolerancje i rozrzucenie replik dla UPF.
+// Błędna konfiguracja env jest logowana i pomijana (nie blokuje admission).
+func ensureUpfPlacement(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	if strings.EqualFold(strings.TrimSpace(meta.Annotations[upfPlacementAnnotation]), "false") {
+		return
+	}
+	// Pod przypisany ręcznie do węzła – scheduler nie bierze udziału
+	if spec.NodeName != "" {
+		return
+	}
+
+	labels, err := parseNodeLabels(upfNodeLabels)
+	if err != nil {
+		log.Printf("UPF_NODE_LABELS: %v", err)
+	} else {
+		ensureUpfNodeAffinity(spec, labels)
+	}
+
+	tols, err := parseNodeTaints(upfNodeTaints)
+	if err != nil {
+		log.Printf("UPF_NODE_TAINTS: %v", err)
+	} else {
+		ensureTolerations(spec, tols)
+	}
+
+	ensureUpfSpread(meta, spec)
+}
+
+// node affinity tylko gdy użytkownik nie ustawił ani nodeSelector, ani nodeAffinity
+func ensureUpfNodeAffinity(spec *corev1.PodSpec, labels [][2]string) {
+	if len(labels) == 0 || len(spec.NodeSelector) > 0 {
+		return
+	}
+	if spec.Affinity != nil && spec.Affinity.NodeAffinity != nil {
+		return
+	}
+
+	var exprs []corev1.NodeSelectorRequirement
+	for _, kv := range labels {
+		exprs = append(exprs, corev1.NodeSelectorRequirement{
+			Key:      kv[0],
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{kv[1]},
+		})
+	}
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	spec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: exprs}},
+		},
+	}
+}
+
+// tolerancje dopisywane tylko dla kluczy/efektów, których Pod jeszcze nie toleruje
+func ensureTolerations(spec *corev1.PodSpec, tols []corev1.Toleration) {
+	for _, tol := range tols {
+		found := false
+		for _, t := range spec.Tolerations {
+			if t.Key == tol.Key && (t.Effect == tol.Effect || t.Effect == "") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			spec.Tolerations = append(spec.Tolerations, tol)
+		}
+	}
+}
+
+// upfSpreadSelector – UPF-y tego samego slice'a (label slice-id kopiowany
+// z anotacji); bez slice'a – repliki tego samego workloadu
+func upfSpreadSelector(meta *metav1.ObjectMeta) *metav1.LabelSelector {
+	keys := []string{nfLabelKey, "app.kubernetes.io/name", "app"}
+	if meta.Labels[sliceIdAnnotation] != "" {
+		keys = []string{nfLabelKey, sliceIdAnnotation}
+	}
+	sel := map[string]string{}
+	for _, k := range keys {
+		if v := meta.Labels[k]; v != "" {
+			sel[k] = v
+		}
+	}
+	if len(sel) == 0 {
+		return nil
+	}
+	return &metav1.LabelSelector{MatchLabels: sel}
+}
+
+// anti-affinity + topologySpreadConstraints, o ile użytkownik nie ustawił własnych
+func ensureUpfSpread(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	policy := strings.ToLower(strings.TrimSpace(upfSpreadPolicy))
+	if policy != "soft" && policy != "hard" {
+		return
+	}
+	sel := upfSpreadSelector(meta)
+	if sel == nil {
+		return
+	}
+
+	if spec.Affinity == nil || spec.Affinity.PodAntiAffinity == nil {
+		term := corev1.PodAffinityTerm{LabelSelector: sel, TopologyKey: upfTopologyKey}
+		anti := &corev1.PodAntiAffinity{}
+		if policy == "hard" {
+			anti.RequiredDuringSchedulingIgnoredDuringExecution = []corev1.PodAffinityTerm{term}
+		} else {
+			anti.PreferredDuringSchedulingIgnoredDuringExecution = []corev1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: term},
+			}
+		}
+		if spec.Affinity == nil {
+			spec.Affinity = &corev1.Affinity{}
+		}
+		spec.Affinity.PodAntiAffinity = anti
+	}
+
+	if len(spec.TopologySpreadConstraints) == 0 {
+		when := corev1.ScheduleAnyway
+		if policy == "hard" {
+			when = corev1.DoNotSchedule
+		}
+		spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+			MaxSkew:           1,
+			TopologyKey:       upfTopologyKey,
+			WhenUnsatisfiable: when,
+			LabelSelector:     sel.DeepCopy(),
+		}}
+	}
+}
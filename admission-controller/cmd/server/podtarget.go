@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// podTarget – wspólny widok na "coś, co opisuje Poda": goły Pod albo
+// szablon Poda w workloadzie. Każda reguła mutująca/walidująca operuje na
+// podTarget, więc działa identycznie dla Poda i dla wszystkich workloadów.
+type podTarget struct {
+	Kind string
+
+	// Object – zdekodowany obiekt najwyższego poziomu (Pod, Deployment, ...)
+	Object runtime.Object
+
+	// Owner – metadane obiektu najwyższego poziomu (dla Poda == Meta)
+	Owner *metav1.ObjectMeta
+
+	// Meta / Spec – metadane i spec Poda (dla workloadów: z template)
+	Meta *metav1.ObjectMeta
+	Spec *corev1.PodSpec
+
+	// PatchBase – JSON Pointer do template ("" dla Poda)
+	PatchBase string
+
+	// FieldBase – ścieżka do template w komunikatach błędów (nil dla Poda)
+	FieldBase *field.Path
+}
+
+// isPodTargetKind – czy dany Kind niesie w sobie spec Poda
+func isPodTargetKind(kind string) bool {
+	switch kind {
+	case "Pod", "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob":
+		return true
+	}
+	return false
+}
+
+func decodePodTarget(raw []byte, kind string) (*podTarget, error) {
+	t := &podTarget{Kind: kind}
+
+	switch kind {
+	case "Pod":
+		obj := &corev1.Pod{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode pod: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.Meta = &obj.ObjectMeta
+		t.Spec = &obj.Spec
+		return t, nil
+	case "Deployment":
+		obj := &appsv1.Deployment{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode deployment: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.Template, "/spec/template", field.NewPath("spec", "template"))
+	case "StatefulSet":
+		obj := &appsv1.StatefulSet{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode statefulset: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.Template, "/spec/template", field.NewPath("spec", "template"))
+	case "DaemonSet":
+		obj := &appsv1.DaemonSet{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode daemonset: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.Template, "/spec/template", field.NewPath("spec", "template"))
+	case "ReplicaSet":
+		obj := &appsv1.ReplicaSet{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode replicaset: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.Template, "/spec/template", field.NewPath("spec", "template"))
+	case "Job":
+		obj := &batchv1.Job{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode job: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.Template, "/spec/template", field.NewPath("spec", "template"))
+	case "CronJob":
+		obj := &batchv1.CronJob{}
+		if _, _, err := deserializer.Decode(raw, nil, obj); err != nil {
+			return nil, fmt.Errorf("decode cronjob: %w", err)
+		}
+		t.Object = obj
+		t.Owner = &obj.ObjectMeta
+		t.setTemplate(&obj.Spec.JobTemplate.Spec.Template, "/spec/jobTemplate/spec/template",
+			field.NewPath("spec", "jobTemplate", "spec", "template"))
+	default:
+		return nil, nil
+	}
+
+	return t, nil
+}
+
+func (t *podTarget) setTemplate(tpl *corev1.PodTemplateSpec, patchBase string, fieldBase *field.Path) {
+	t.Meta = &tpl.ObjectMeta
+	t.Spec = &tpl.Spec
+	t.PatchBase = patchBase
+	t.FieldBase = fieldBase
+}
+
+// isTemplate – true dla workloadów (metadane template != metadane obiektu)
+func (t *podTarget) isTemplate() bool {
+	return t.FieldBase != nil
+}
+
+// metaPatchPath / specPatchPath – JSON Pointery do metadata i spec Poda
+func (t *podTarget) metaPatchPath() string {
+	return t.PatchBase + "/metadata"
+}
+
+func (t *podTarget) specPatchPath() string {
+	return t.PatchBase + "/spec"
+}
+
+// field – ścieżka do pola Poda w komunikatach błędów, np. t.field("spec", "volumes")
+func (t *podTarget) field(name string, more ...string) *field.Path {
+	if t.FieldBase == nil {
+		return field.NewPath(name, more...)
+	}
+	return t.FieldBase.Child(name, more...)
+}
+
+// annotation – wartość anotacji Poda (template), bez białych znaków
+func (t *podTarget) annotation(key string) string {
+	if t.Meta.Annotations == nil {
+		return ""
+	}
+	return strings.TrimSpace(t.Meta.Annotations[key])
+}
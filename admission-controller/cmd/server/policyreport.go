@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PolicyReport (wgpolicyk8s.io/v1alpha2): wyniki audytu w tle zapisywane
+// obok raportów Kyverno, żeby reguły free5gc były w tych samych dashboardach
+// (Policy Reporter itp.). Per namespace jeden PolicyReport free5gc-admission
+// z naruszeniami (rule ID, severity, zasób, komunikat); ClusterPolicyReport
+// free5gc-admission zbiera namespace'y, których nie dało się przeskanować.
+// Bez CRD (Kyverno / wg-policy) zostaje tylko ConfigMapa z audit.go.
+
+var (
+	// POLICY_REPORTS=false – bez PolicyReport/ClusterPolicyReport
+	policyReportsEnabled = getEnvBool("POLICY_REPORTS", true)
+
+	policyReportGVR        = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "policyreports"}
+	clusterPolicyReportGVR = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "clusterpolicyreports"}
+
+	policyReportName   = "free5gc-admission"
+	policyReportSource = "free5gc-admission"
+)
+
+// policyReportResult – wpis results[] w schemacie wgpolicyk8s.io/v1alpha2
+type policyReportResult struct {
+	Source     string                   `json:"source"`
+	Policy     string                   `json:"policy"`
+	Rule       string                   `json:"rule,omitempty"`
+	Category   string                   `json:"category,omitempty"`
+	Severity   string                   `json:"severity,omitempty"`
+	Result     string                   `json:"result"`
+	Scored     bool                     `json:"scored"`
+	Message    string                   `json:"message,omitempty"`
+	Resources  []corev1.ObjectReference `json:"resources,omitempty"`
+	Properties map[string]string        `json:"properties,omitempty"`
+	Timestamp  metav1.Timestamp         `json:"timestamp"`
+}
+
+type policyReportSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+var (
+	ruleIndexRe = regexp.MustCompile(`\[\d+\]`)
+	ruleKeyRe   = regexp.MustCompile(`\[([^\]]*)\]`)
+	ruleSlugRe  = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// auditRuleID – stały identyfikator reguły ze ścieżki pola: bez indeksów,
+// z kluczem anotacji/danych bez prefiksu domeny i bez prefiksu template'u,
+// żeby Pod i workloady dawały ten sam rule ID
+// (spec.template.spec.containers[0].image -> free5gc-containers-image)
+func auditRuleID(fieldPath string) string {
+	p := fieldPath
+	for _, prefix := range []string{"spec.jobTemplate.spec.template.", "spec.template."} {
+		p = strings.TrimPrefix(p, prefix)
+	}
+	p = strings.TrimPrefix(p, "spec.")
+	p = ruleIndexRe.ReplaceAllString(p, "")
+	p = ruleKeyRe.ReplaceAllStringFunc(p, func(m string) string {
+		key := strings.Trim(m, "[]")
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			key = key[i+1:]
+		}
+		return "." + key
+	})
+	p = strings.Trim(ruleSlugRe.ReplaceAllString(strings.ToLower(p), "-"), "-")
+	if p == "" {
+		p = "object"
+	}
+	return "free5gc-" + p
+}
+
+// auditSeverity – Forbidden (zakazane ustawienie) = high, reszta = medium;
+// błąd wewnętrzny (API, rejestr) nie ma severity
+func auditSeverity(t field.ErrorType) string {
+	switch t {
+	case field.ErrorTypeInternal:
+		return ""
+	case field.ErrorTypeForbidden:
+		return "high"
+	}
+	return "medium"
+}
+
+// auditCategory – kategoria reguły wg rodzaju zasobu
+func auditCategory(kind string) string {
+	switch {
+	case isPodTargetKind(kind):
+		return "free5gc Workloads"
+	case kind == "Service":
+		return "free5gc Services"
+	case kind == "ConfigMap":
+		return "free5gc NF Config"
+	}
+	return "free5gc"
+}
+
+// policyReportResults – wyniki audytu w formacie PolicyReport: pass per
+// obiekt bez naruszeń, fail/error per naruszenie; summary liczy wyniki
+// (results[]), tak jak w schemacie wgpolicyk8s.io
+func policyReportResults(rep *auditReport) ([]policyReportResult, policyReportSummary) {
+	ts := metav1.Timestamp{Seconds: rep.Timestamp.Unix()}
+	var sum policyReportSummary
+	out := make([]policyReportResult, 0, len(rep.passed)+len(rep.Results))
+	for _, ref := range rep.passed {
+		out = append(out, policyReportResult{
+			Source:    policyReportSource,
+			Policy:    policyReportName,
+			Category:  auditCategory(ref.Kind),
+			Result:    "pass",
+			Scored:    true,
+			Message:   "obiekt spełnia reguły admission",
+			Resources: []corev1.ObjectReference{ref},
+			Timestamp: ts,
+		})
+		sum.Pass++
+	}
+	for _, r := range rep.Results {
+		res := policyReportResult{
+			Source:    policyReportSource,
+			Policy:    policyReportName,
+			Rule:      r.Rule,
+			Category:  auditCategory(r.Resource.Kind),
+			Severity:  r.Severity,
+			Result:    "fail",
+			Scored:    true,
+			Message:   r.Message,
+			Resources: []corev1.ObjectReference{r.Resource},
+			Properties: map[string]string{
+				"field":     r.Field,
+				"errorType": r.Type,
+			},
+			Timestamp: ts,
+		}
+		if r.Type == string(field.ErrorTypeInternal) {
+			res.Result = "error"
+			sum.Error++
+		} else {
+			sum.Fail++
+		}
+		out = append(out, res)
+	}
+	return out, sum
+}
+
+// policyReportWriter – zapis raportów przez klienta dynamicznego
+type policyReportWriter struct {
+	client dynamic.Interface
+}
+
+// newPolicyReportWriter – błąd, gdy CRD wgpolicyk8s.io nie są zainstalowane
+func newPolicyReportWriter(clientset kubernetes.Interface, client dynamic.Interface) (*policyReportWriter, error) {
+	gv := policyReportGVR.GroupVersion().String()
+	if _, err := clientset.Discovery().ServerResourcesForGroupVersion(gv); err != nil {
+		return nil, fmt.Errorf("discovery %s: %w", gv, err)
+	}
+	return &policyReportWriter{client: client}, nil
+}
+
+func policyReportLabels() map[string]string {
+	return map[string]string{
+		managedByLabelKey: managedByLabelValue,
+		componentLabelKey: "audit",
+	}
+}
+
+// buildPolicyReport – (Cluster)PolicyReport jako obiekt nieustrukturyzowany
+func buildPolicyReport(kind, namespace string, results []policyReportResult, sum policyReportSummary) (*unstructured.Unstructured, error) {
+	obj := struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+		Summary           policyReportSummary  `json:"summary"`
+		Results           []policyReportResult `json:"results"`
+	}{
+		TypeMeta: metav1.TypeMeta{APIVersion: policyReportGVR.GroupVersion().String(), Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policyReportName,
+			Namespace: namespace,
+			Labels:    policyReportLabels(),
+		},
+		Summary: sum,
+		Results: results,
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: m}, nil
+}
+
+// upsert – create albo update summary/results istniejącego raportu
+func (w *policyReportWriter) upsert(ctx context.Context, api dynamic.ResourceInterface, want *unstructured.Unstructured) error {
+	cur, err := api.Get(ctx, want.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = api.Create(ctx, want, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	cur.SetLabels(want.GetLabels())
+	cur.Object["summary"] = want.Object["summary"]
+	cur.Object["results"] = want.Object["results"]
+	_, err = api.Update(ctx, cur, metav1.UpdateOptions{})
+	return err
+}
+
+// write – PolicyReport per przeskanowany namespace, usunięcie raportów
+// z namespace'ów wyłączonych z admission i ClusterPolicyReport z błędami skanu
+func (w *policyReportWriter) write(ctx context.Context, reports []*auditReport, scanErrors map[string]error) error {
+	var errs []string
+	scanned := map[string]bool{}
+	for _, rep := range reports {
+		scanned[rep.Namespace] = true
+		results, sum := policyReportResults(rep)
+		obj, err := buildPolicyReport("PolicyReport", rep.Namespace, results, sum)
+		if err == nil {
+			err = w.upsert(ctx, w.client.Resource(policyReportGVR).Namespace(rep.Namespace), obj)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", rep.Namespace, err))
+		}
+	}
+
+	// raporty pozostawione w namespace'ach, których już nie audytujemy
+	// (namespace ze skanem zakończonym błędem zachowuje poprzedni raport)
+	existing, err := w.client.Resource(policyReportGVR).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: managedByLabelKey + "=" + managedByLabelValue,
+	})
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("list policyreports: %v", err))
+	} else {
+		for _, r := range existing.Items {
+			ns := r.GetNamespace()
+			if r.GetName() != policyReportName || scanned[ns] || scanErrors[ns] != nil {
+				continue
+			}
+			err := w.client.Resource(policyReportGVR).Namespace(ns).Delete(ctx, r.GetName(), metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Sprintf("%s: delete: %v", ns, err))
+			}
+		}
+	}
+
+	ts := metav1.Timestamp{Seconds: time.Now().Unix()}
+	results := []policyReportResult{}
+	var sum policyReportSummary
+	failedNs := make([]string, 0, len(scanErrors))
+	for ns := range scanErrors {
+		failedNs = append(failedNs, ns)
+	}
+	sort.Strings(failedNs)
+	for _, ns := range failedNs {
+		results = append(results, policyReportResult{
+			Source:    policyReportSource,
+			Policy:    policyReportName,
+			Rule:      "free5gc-audit-scan",
+			Category:  auditCategory(""),
+			Result:    "error",
+			Message:   fmt.Sprintf("audyt namespace'u nieudany: %v", scanErrors[ns]),
+			Resources: []corev1.ObjectReference{{APIVersion: "v1", Kind: "Namespace", Name: ns}},
+			Timestamp: ts,
+		})
+		sum.Error++
+	}
+	obj, err := buildPolicyReport("ClusterPolicyReport", "", results, sum)
+	if err == nil {
+		err = w.upsert(ctx, w.client.Resource(clusterPolicyReportGVR), obj)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("cluster report: %v", err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
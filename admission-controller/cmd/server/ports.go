@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Profile portów NF: porty punktów referencyjnych 5G dopisywane przez
+// mutator do głównego kontenera NF (w Service: nazwy i protokoły), plus
+// walidacja protokołu na dobrze znanych portach – typowy błąd to N2
+// (SCTP 38412) zadeklarowane jako TCP, bo TCP jest domyślnym protokołem.
+
+// porty punktów referencyjnych: N2 (NGAP), N4 (PFCP) i N3 (GTP-U)
+const (
+	n2Port   = int32(38412)
+	pfcpPort = int32(8805)
+	gtpuPort = int32(2152)
+)
+
+var (
+	// SBI_PORT – port SBI (HTTP/2) w configach free5gc
+	sbiPort = int32(getEnvInt("SBI_PORT", 8000))
+
+	// 5g.kkarczmarek.dev/default-ports=false – bez dopisywania portów z profilu
+	defaultPortsAnnotation = "5g.kkarczmarek.dev/default-ports"
+)
+
+// nfPort – port z profilu NF (Name = nazwa portu w kontenerze / Service)
+type nfPort struct {
+	Name     string
+	Port     int32
+	Protocol corev1.Protocol
+}
+
+// nfPortProfiles – porty per NF (klucz = label nf)
+func nfPortProfiles() map[string][]nfPort {
+	sbi := nfPort{Name: "sbi", Port: sbiPort, Protocol: corev1.ProtocolTCP}
+	return map[string][]nfPort{
+		"amf": {{Name: "n2", Port: n2Port, Protocol: corev1.ProtocolSCTP}, sbi},
+		"smf": {{Name: "pfcp", Port: pfcpPort, Protocol: corev1.ProtocolUDP}, sbi},
+		"nrf": {sbi},
+		"upf": {
+			{Name: "pfcp", Port: pfcpPort, Protocol: corev1.ProtocolUDP},
+			{Name: "gtpu", Port: gtpuPort, Protocol: corev1.ProtocolUDP},
+		},
+	}
+}
+
+// wellKnownPort – punkt referencyjny z protokołem narzuconym przez 3GPP
+type wellKnownPort struct {
+	Name     string
+	Protocol corev1.Protocol
+	Iface    string
+}
+
+var wellKnownPorts = map[int32]wellKnownPort{
+	n2Port:   {"n2", corev1.ProtocolSCTP, "N2 (NGAP)"},
+	pfcpPort: {"pfcp", corev1.ProtocolUDP, "N4 (PFCP)"},
+	gtpuPort: {"gtpu", corev1.ProtocolUDP, "N3 (GTP-U)"},
+}
+
+// podNF – typ NF Poda: label nf, a UPF także po heurystyce isUpfTarget
+func podNF(t *podTarget) string {
+	if isUpfTarget(t) {
+		return "upf"
+	}
+	return t.Meta.Labels[nfLabelKey]
+}
+
+// serviceNF – label nf Service'u albo nf z jego selektora
+func serviceNF(svc *corev1.Service) string {
+	if nf := svc.Labels[nfLabelKey]; nf != "" {
+		return nf
+	}
+	return svc.Spec.Selector[nfLabelKey]
+}
+
+func defaultPortsDisabled(meta *metav1.ObjectMeta) bool {
+	return strings.EqualFold(strings.TrimSpace(meta.Annotations[defaultPortsAnnotation]), "false")
+}
+
+// ensureNFDefaultPorts – porty z profilu NF na kontenerze o nazwie NF (albo
+// pierwszym). Port o tym numerze albo nazwie zadeklarowany przez użytkownika
+// wygrywa (zły protokół odrzuci walidator).
+func ensureNFDefaultPorts(meta *metav1.ObjectMeta, spec *corev1.PodSpec, nf string) {
+	profile := nfPortProfiles()[nf]
+	if len(profile) == 0 || len(spec.Containers) == 0 || defaultPortsDisabled(meta) {
+		return
+	}
+
+	idx := 0
+	for i, c := range spec.Containers {
+		if c.Name == nf {
+			idx = i
+			break
+		}
+	}
+	c := &spec.Containers[idx]
+
+	for _, p := range profile {
+		found := false
+		for _, cp := range c.Ports {
+			if cp.ContainerPort == p.Port || cp.Name == p.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Ports = append(c.Ports, corev1.ContainerPort{
+				Name:          p.Name,
+				ContainerPort: p.Port,
+				Protocol:      p.Protocol,
+			})
+		}
+	}
+}
+
+// wellKnownFor – punkt referencyjny portu Service'u (port, a jeśli nie
+// jest dobrze znany – liczbowy targetPort)
+func wellKnownFor(sp *corev1.ServicePort) (wellKnownPort, bool) {
+	if wk, ok := wellKnownPorts[sp.Port]; ok {
+		return wk, true
+	}
+	if sp.TargetPort.Type == intstr.Int {
+		wk, ok := wellKnownPorts[sp.TargetPort.IntVal]
+		return wk, ok
+	}
+	return wellKnownPort{}, false
+}
+
+// ensureServicePorts – porty Service'u na punktach referencyjnych 5G:
+//   - TCP (domyślny – apiserver nadaje go przed mutacją, więc jawnego TCP
+//     nie da się odróżnić) albo brak protokołu -> SCTP/UDP, o ile Service
+//     nie ma już tego portu z właściwym protokołem; inny jawny protokół
+//     (np. UDP na 38412) zostaje i odrzuca go walidator,
+//   - nienazwane porty dostają nazwę z profilu NF albo punktu (n2, sbi, pfcp, gtpu).
+func ensureServicePorts(svc *corev1.Service) {
+	if defaultPortsDisabled(&svc.ObjectMeta) {
+		return
+	}
+
+	type portKey struct {
+		Port     int32
+		Protocol corev1.Protocol
+	}
+	declared := map[portKey]bool{}
+	used := map[string]bool{}
+	for _, sp := range svc.Spec.Ports {
+		declared[portKey{sp.Port, sp.Protocol}] = true
+		used[sp.Name] = true
+	}
+
+	for i := range svc.Spec.Ports {
+		sp := &svc.Spec.Ports[i]
+		wk, ok := wellKnownFor(sp)
+		if !ok || (sp.Protocol != "" && sp.Protocol != corev1.ProtocolTCP) {
+			continue
+		}
+		if declared[portKey{sp.Port, wk.Protocol}] {
+			continue
+		}
+		sp.Protocol = wk.Protocol
+		declared[portKey{sp.Port, wk.Protocol}] = true
+	}
+
+	profile := nfPortProfiles()[serviceNF(svc)]
+	for i := range svc.Spec.Ports {
+		sp := &svc.Spec.Ports[i]
+		if sp.Name != "" {
+			continue
+		}
+		name := ""
+		for _, p := range profile {
+			target := sp.TargetPort.Type == intstr.Int && sp.TargetPort.IntVal == p.Port
+			if (sp.Port == p.Port || target) && sp.Protocol == p.Protocol {
+				name = p.Name
+				break
+			}
+		}
+		if wk, ok := wellKnownFor(sp); ok && name == "" && sp.Protocol == wk.Protocol {
+			name = wk.Name
+		}
+		if name != "" && !used[name] {
+			sp.Name = name
+			used[name] = true
+		}
+	}
+}
+
+func wellKnownPortError(fp *field.Path, port int32, proto corev1.Protocol) *field.Error {
+	wk, ok := wellKnownPorts[port]
+	if !ok {
+		return nil
+	}
+	if proto == "" {
+		proto = corev1.ProtocolTCP
+	}
+	if proto == wk.Protocol {
+		return nil
+	}
+	return field.Invalid(fp, proto,
+		fmt.Sprintf("port %d to %s – wymagany protokół %s", port, wk.Iface, wk.Protocol))
+}
+
+// validateContainerPortProtocols – protokół na dobrze znanych portach 5G
+func validateContainerPortProtocols(t *podTarget) field.ErrorList {
+	var errs field.ErrorList
+	check := func(containers []corev1.Container, fp *field.Path) {
+		for i, c := range containers {
+			for j, p := range c.Ports {
+				if err := wellKnownPortError(fp.Index(i).Child("ports").Index(j).Child("protocol"), p.ContainerPort, p.Protocol); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	check(t.Spec.Containers, t.field("spec", "containers"))
+	check(t.Spec.InitContainers, t.field("spec", "initContainers"))
+	return errs
+}
+
+// validateServicePortProtocols – port i liczbowy targetPort Service'u
+func validateServicePortProtocols(svc *corev1.Service) field.ErrorList {
+	var errs field.ErrorList
+	fp := field.NewPath("spec", "ports")
+	for i, sp := range svc.Spec.Ports {
+		pfp := fp.Index(i).Child("protocol")
+		if err := wellKnownPortError(pfp, sp.Port, sp.Protocol); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sp.TargetPort.Type == intstr.Int {
+			if err := wellKnownPortError(pfp, sp.TargetPort.IntVal, sp.Protocol); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
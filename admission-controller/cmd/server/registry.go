@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// rejestry po HTTP (np. lokalny localhost:32000 z microk8s), rozdzielone przecinkami
+	insecureRegistries = getEnv("INSECURE_REGISTRIES", "")
+	registryTimeout    = getEnvDuration("REGISTRY_TIMEOUT", 3*time.Second)
+)
+
+// maksymalny rozmiar manifestu / bloba czytanego z rejestru (podpisy są małe)
+const maxRegistryBody = 4 << 20
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// registryClient – to, czego webhook potrzebuje od rejestru obrazów.
+// Interfejs, żeby w testach podstawić lokalny rejestr OCI albo fake.
+type registryClient interface {
+	digestResolver
+	// Manifest pobiera manifest po tagu albo digeście
+	Manifest(ctx context.Context, ref imageRef, reference string) ([]byte, error)
+	// Blob pobiera blob i sprawdza, czy jego sha256 zgadza się z digestem
+	Blob(ctx context.Context, ref imageRef, digest string) ([]byte, error)
+}
+
+// httpRegistryClient – Docker Registry HTTP API v2, z obsługą anonimowego
+// tokena Bearer (Docker Hub, GHCR, quay.io)
+type httpRegistryClient struct {
+	client   *http.Client
+	insecure map[string]bool
+}
+
+func newHTTPRegistryClient() *httpRegistryClient {
+	insecure := map[string]bool{}
+	for _, r := range strings.Split(insecureRegistries, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			insecure[r] = true
+		}
+	}
+	return &httpRegistryClient{
+		client: &http.Client{
+			Timeout: registryTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+			},
+		},
+		insecure: insecure,
+	}
+}
+
+func (r *httpRegistryClient) baseURL(registry string) string {
+	host := registry
+	if host == defaultRegistry {
+		host = "registry-1.docker.io"
+	}
+	if r.insecure[registry] {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+func (r *httpRegistryClient) Resolve(ctx context.Context, ref imageRef) (string, error) {
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	u := fmt.Sprintf("%s/v2/%s/manifests/%s", r.baseURL(ref.Registry), ref.Repository, tag)
+
+	resp, err := r.do(ctx, http.MethodHead, u, strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !sha256DigestRegex.MatchString(digest) {
+		return "", fmt.Errorf("HEAD %s: missing or invalid Docker-Content-Digest %q", u, digest)
+	}
+	return digest, nil
+}
+
+func (r *httpRegistryClient) Manifest(ctx context.Context, ref imageRef, reference string) ([]byte, error) {
+	u := fmt.Sprintf("%s/v2/%s/manifests/%s", r.baseURL(ref.Registry), ref.Repository, reference)
+	resp, err := r.do(ctx, http.MethodGet, u, strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistryBody))
+	if err != nil {
+		return nil, err
+	}
+	// pobranie po digeście – treść musi się z nim zgadzać (tag nie ma czego sprawdzić)
+	if sha256DigestRegex.MatchString(reference) {
+		if got := sha256Digest(body); got != reference {
+			return nil, fmt.Errorf("GET %s: manifest digest %s does not match", u, got)
+		}
+	}
+	return body, nil
+}
+
+func (r *httpRegistryClient) Blob(ctx context.Context, ref imageRef, digest string) ([]byte, error) {
+	u := fmt.Sprintf("%s/v2/%s/blobs/%s", r.baseURL(ref.Registry), ref.Repository, digest)
+	resp, err := r.do(ctx, http.MethodGet, u, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistryBody))
+	if err != nil {
+		return nil, err
+	}
+	if got := sha256Digest(body); got != digest {
+		return nil, fmt.Errorf("GET %s: content digest %s does not match", u, got)
+	}
+	return body, nil
+}
+
+// do wykonuje zapytanie, a przy 401 pobiera token wg WWW-Authenticate i ponawia
+func (r *httpRegistryClient) do(ctx context.Context, method, u, accept string) (*http.Response, error) {
+	resp, err := r.send(ctx, method, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		token, err := r.fetchToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		resp, err = r.send(ctx, method, u, accept, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, u, resp.Status)
+	}
+	return resp, nil
+}
+
+func (r *httpRegistryClient) send(ctx context.Context, method, u, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, u, err)
+	}
+	return resp, nil
+}
+
+// fetchToken – anonimowy token wg nagłówka WWW-Authenticate: Bearer realm=...,service=...,scope=...
+func (r *httpRegistryClient) fetchToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	params := parseAuthParams(challenge[len("bearer "):])
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("auth challenge without realm: %q", challenge)
+	}
+
+	q := url.Values{}
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+	if s := params["scope"]; s != "" {
+		q.Set("scope", s)
+	}
+	u := realm
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	resp, err := r.send(ctx, http.MethodGet, u, "", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", realm, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseAuthParams – realm="...",service="...",scope="..."
+func parseAuthParams(s string) map[string]string {
+	out := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		out[key] = val
+	}
+	return out
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
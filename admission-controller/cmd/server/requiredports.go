@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Anotacja required-ports: lista wpisów rozdzielonych przecinkiem/spacją:
+//
+//	8805/udp        – numer + protokół
+//	80              – numer; protokół z punktu referencyjnego 5G albo TCP
+//	sbi, pfcp/udp   – nazwany port (bez protokołu: dowolny)
+//	30000-30010/udp – zakres, wymagany każdy port
+//
+// Sam numer portu nie wystarcza – 8805/TCP nie spełnia wymagania PFCP.
+
+// maxRequiredPortRange – limit rozmiaru zakresu (żeby "1-65535" nie
+// generował 65 tys. błędów)
+const maxRequiredPortRange = 1024
+
+// portRequirement – jeden wpis anotacji; Name != "" dla portu nazwanego
+type portRequirement struct {
+	Name     string
+	From, To int32
+	Protocol corev1.Protocol
+}
+
+func (r portRequirement) String() string {
+	var s string
+	switch {
+	case r.Name != "":
+		s = r.Name
+	case r.From != r.To:
+		s = fmt.Sprintf("%d-%d", r.From, r.To)
+	default:
+		s = strconv.Itoa(int(r.From))
+	}
+	if r.Protocol != "" {
+		s += "/" + string(r.Protocol)
+	}
+	return s
+}
+
+// matches – czy port (numer, nazwa, protokół) spełnia wymaganie
+func (r portRequirement) matches(port int32, name string, proto corev1.Protocol) bool {
+	if r.Protocol != "" && protocolOf(proto) != r.Protocol {
+		return false
+	}
+	if r.Name != "" {
+		return name == r.Name
+	}
+	return port >= r.From && port <= r.To
+}
+
+// sameTarget – ten sam numer/nazwa, bez patrzenia na protokół
+func (r portRequirement) sameTarget(port int32, name string) bool {
+	if r.Name != "" {
+		return name == r.Name
+	}
+	return port >= r.From && port <= r.To
+}
+
+func protocolOf(p corev1.Protocol) corev1.Protocol {
+	if p == "" {
+		return corev1.ProtocolTCP
+	}
+	return p
+}
+
+func parsePortNumber(s string) (int32, error) {
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if v <= 0 || v > 65535 {
+		return 0, fmt.Errorf("port out of range: %d", v)
+	}
+	return int32(v), nil
+}
+
+// parsePortRequirements – anotacja required-ports -> lista wymagań
+func parsePortRequirements(raw string) ([]portRequirement, error) {
+	splitFn := func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	}
+	var out []portRequirement
+	for _, e := range strings.FieldsFunc(strings.TrimSpace(raw), splitFn) {
+		spec, proto, hasProto := strings.Cut(e, "/")
+		var r portRequirement
+		if hasProto {
+			switch p := corev1.Protocol(strings.ToUpper(proto)); p {
+			case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+				r.Protocol = p
+			default:
+				return nil, fmt.Errorf("invalid protocol %q in %q (expected tcp, udp or sctp)", proto, e)
+			}
+		}
+
+		from, to, isRange := strings.Cut(spec, "-")
+		switch {
+		case isRange:
+			f, err := parsePortNumber(from)
+			if err != nil {
+				return nil, err
+			}
+			t, err := parsePortNumber(to)
+			if err != nil {
+				return nil, err
+			}
+			if f > t || t-f >= maxRequiredPortRange {
+				return nil, fmt.Errorf("invalid port range %q (max %d ports)", spec, maxRequiredPortRange)
+			}
+			r.From, r.To = f, t
+			if r.Protocol == "" {
+				r.Protocol = corev1.ProtocolTCP
+			}
+		case spec != "" && strings.Trim(spec, "0123456789") == "":
+			p, err := parsePortNumber(spec)
+			if err != nil {
+				return nil, err
+			}
+			r.From, r.To = p, p
+			if r.Protocol == "" {
+				r.Protocol = corev1.ProtocolTCP
+				if wk, ok := wellKnownPorts[p]; ok {
+					r.Protocol = wk.Protocol
+				}
+			}
+		default:
+			if msgs := validation.IsValidPortName(spec); len(msgs) > 0 {
+				return nil, fmt.Errorf("invalid port name %q: %s", spec, strings.Join(msgs, "; "))
+			}
+			r.Name = spec
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// wanted – konkretne porty wymagania (dla nazwanego portu: jeden wpis 0)
+func (r portRequirement) wanted() []int32 {
+	if r.Name != "" {
+		return []int32{0}
+	}
+	var out []int32
+	for p := r.From; p <= r.To; p++ {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (r portRequirement) label(port int32) string {
+	if r.Name != "" {
+		return r.String()
+	}
+	return fmt.Sprintf("%d/%s", port, r.Protocol)
+}
+
+// validateRequiredContainerPorts – każdy wymagany port musi być wystawiony
+// przez któryś kontener; port o dobrym numerze/nazwie, ale złym protokole
+// jest wskazywany wprost
+func validateRequiredContainerPorts(reqs []portRequirement, containers []corev1.Container, fp *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, r := range reqs {
+		var missing []string
+		for _, want := range r.wanted() {
+			port, found := want, false
+			var mismatch *field.Error
+			for ci, c := range containers {
+				for pi, cp := range c.Ports {
+					if r.Name == "" && cp.ContainerPort != port {
+						continue
+					}
+					if r.matches(cp.ContainerPort, cp.Name, cp.Protocol) {
+						found = true
+					} else if mismatch == nil && r.sameTarget(cp.ContainerPort, cp.Name) {
+						mismatch = field.Invalid(fp.Index(ci).Child("ports").Index(pi).Child("protocol"), cp.Protocol,
+							fmt.Sprintf("wymagany port %s (z %s), kontener %q wystawia %d/%s",
+								r.label(port), requiredPortsAnnotation, c.Name, cp.ContainerPort, protocolOf(cp.Protocol)))
+					}
+				}
+			}
+			switch {
+			case found:
+			case mismatch != nil:
+				errs = append(errs, mismatch)
+			default:
+				missing = append(missing, r.label(port))
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, field.Forbidden(fp,
+				fmt.Sprintf("wymagany port %s (z %s) nie jest wystawiony przez żaden kontener",
+					strings.Join(missing, ", "), requiredPortsAnnotation)))
+		}
+	}
+	return errs
+}
+
+// validateRequiredServicePorts – wymagane porty w spec.ports Service'u oraz
+// spójność targetPort z portami Podów wybieranych przez Service
+func validateRequiredServicePorts(reqs []portRequirement, svc *corev1.Service, namespace string) field.ErrorList {
+	var errs field.ErrorList
+	fp := field.NewPath("spec", "ports")
+
+	matched := map[int]bool{}
+	for _, r := range reqs {
+		var missing []string
+		for _, want := range r.wanted() {
+			found := -1
+			var mismatch *field.Error
+			for i, sp := range svc.Spec.Ports {
+				if r.Name == "" && sp.Port != want {
+					continue
+				}
+				if r.matches(sp.Port, sp.Name, sp.Protocol) {
+					found = i
+					break
+				}
+				if mismatch == nil && r.sameTarget(sp.Port, sp.Name) {
+					mismatch = field.Invalid(fp.Index(i).Child("protocol"), sp.Protocol,
+						fmt.Sprintf("service must expose port %s (required by %s), got %d/%s",
+							r.label(want), requiredPortsAnnotation, sp.Port, protocolOf(sp.Protocol)))
+				}
+			}
+			switch {
+			case found >= 0:
+				matched[found] = true
+			case mismatch != nil:
+				errs = append(errs, mismatch)
+			default:
+				missing = append(missing, r.label(want))
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, field.Forbidden(fp,
+				fmt.Sprintf("service must expose port %s (required by %s)", strings.Join(missing, ", "), requiredPortsAnnotation)))
+		}
+	}
+
+	if len(matched) == 0 || len(svc.Spec.Selector) == 0 {
+		return errs
+	}
+	pods, err := selectedPods(namespace, svc.Spec.Selector)
+	if err != nil {
+		return append(errs, field.InternalError(fp, err))
+	}
+	for i, sp := range svc.Spec.Ports {
+		if !matched[i] {
+			continue
+		}
+		for _, p := range pods {
+			if msg := targetPortProblem(p.Spec.Containers, sp); msg != "" {
+				errs = append(errs, field.Invalid(fp.Index(i).Child("targetPort"), sp.TargetPort.String(),
+					fmt.Sprintf("%s (Pod %s wybierany przez Service)", msg, p.Name)))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// targetPortProblem – targetPort (numer albo nazwa; brak = port) względem
+// portów kontenerów Poda. Nazwany targetPort musi istnieć z protokołem
+// Service'u; numer bez deklaracji w Podzie jest dozwolony (ruch i tak trafi
+// na ten port), ale nie z innym protokołem.
+func targetPortProblem(containers []corev1.Container, sp corev1.ServicePort) string {
+	target := sp.TargetPort
+	if target.Type == intstr.Int && target.IntVal == 0 {
+		target = intstr.FromInt32(sp.Port)
+	}
+	proto := protocolOf(sp.Protocol)
+
+	var other corev1.Protocol
+	for _, c := range containers {
+		for _, cp := range c.Ports {
+			same := (target.Type == intstr.String && cp.Name == target.StrVal) ||
+				(target.Type == intstr.Int && cp.ContainerPort == target.IntVal)
+			if !same {
+				continue
+			}
+			if protocolOf(cp.Protocol) == proto {
+				return ""
+			}
+			other = protocolOf(cp.Protocol)
+		}
+	}
+	switch {
+	case other != "":
+		return fmt.Sprintf("Pod wystawia %s po %s, a Service używa %s", target.String(), other, proto)
+	case target.Type == intstr.String:
+		return fmt.Sprintf("Pod nie ma portu o nazwie %q", target.StrVal)
+	}
+	return ""
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	resourceProfileAnnotation = "5g.kkarczmarek.dev/resource-profile"
+	resourceProfilesFile      = getEnv("RESOURCE_PROFILES_FILE", "/etc/admission/resource-profiles.yaml")
+
+	// ustawiane w main() z RESOURCE_PROFILES_FILE; domyślnie tylko profil
+	// "default" z DEFAULT_REQUEST_* / DEFAULT_LIMIT_*
+	resourceProfiles = builtinResourceProfiles()
+)
+
+const defaultResourceProfile = "default"
+
+// resourceSpec – requests/limits; klucze, których nie ma w profilu, nie są
+// uzupełniane (profil może celowo pominąć np. limit CPU)
+type resourceSpec struct {
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	Limits   corev1.ResourceList `json:"limits,omitempty"`
+}
+
+// resourceProfile – rozmiar NF: główny kontener + nadpisania per nazwa kontenera
+type resourceProfile struct {
+	resourceSpec `json:",inline"`
+
+	// Containers – nadpisania dla konkretnych nazw kontenerów w Podzie
+	Containers map[string]resourceSpec `json:"containers,omitempty"`
+	// InitContainers – rozmiar init containerów (domyślnie initContainers z pliku)
+	InitContainers *resourceSpec `json:"initContainers,omitempty"`
+}
+
+// resourceProfileSet – plik RESOURCE_PROFILES_FILE:
+//
+//	profiles:
+//	  upf:
+//	    requests: {cpu: 80m, memory: 128Mi}
+//	    limits:   {cpu: 400m, memory: 512Mi}
+//	sidecars:
+//	  tcpdump-sidecar: {requests: {cpu: 20m, memory: 32Mi}, ...}
+//	sidecarDefaults: {...}
+//	initContainers: {...}
+type resourceProfileSet struct {
+	Profiles map[string]resourceProfile `json:"profiles"`
+	// Sidecars – znane sidecary (po nazwie kontenera), niezależnie od profilu NF
+	Sidecars map[string]resourceSpec `json:"sidecars,omitempty"`
+	// SidecarDefaults – pozostałe kontenery poza głównym kontenerem NF
+	SidecarDefaults *resourceSpec `json:"sidecarDefaults,omitempty"`
+	// InitContainers – init containery, jeśli profil nie mówi inaczej
+	InitContainers *resourceSpec `json:"initContainers,omitempty"`
+}
+
+func builtinResourceProfiles() *resourceProfileSet {
+	return &resourceProfileSet{
+		Profiles: map[string]resourceProfile{
+			defaultResourceProfile: {resourceSpec: envDefaultResources()},
+		},
+	}
+}
+
+// envDefaultResources – globalne DEFAULT_REQUEST_* / DEFAULT_LIMIT_*
+func envDefaultResources() resourceSpec {
+	return resourceSpec{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(defaultReqCPU),
+			corev1.ResourceMemory: resource.MustParse(defaultReqMemory),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(defaultLimCPU),
+			corev1.ResourceMemory: resource.MustParse(defaultLimMemory),
+		},
+	}
+}
+
+// loadResourceProfiles czyta plik profili; brak pliku = tylko profil "default"
+func loadResourceProfiles(path string) (*resourceProfileSet, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return builtinResourceProfiles(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read resource profiles: %w", err)
+	}
+
+	set := &resourceProfileSet{}
+	if err := yaml.UnmarshalStrict(raw, set); err != nil {
+		return nil, fmt.Errorf("parse resource profiles %s: %w", path, err)
+	}
+	if set.Profiles == nil {
+		set.Profiles = map[string]resourceProfile{}
+	}
+	if _, ok := set.Profiles[defaultResourceProfile]; !ok {
+		set.Profiles[defaultResourceProfile] = resourceProfile{resourceSpec: envDefaultResources()}
+	}
+
+	if errs := set.validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid resource profiles %s: %v", path, errs.ToAggregate())
+	}
+	return set, nil
+}
+
+func (s *resourceProfileSet) validate() field.ErrorList {
+	var errs field.ErrorList
+
+	check := func(spec *resourceSpec, fp *field.Path) {
+		if spec == nil {
+			return
+		}
+		for name, lim := range spec.Limits {
+			if req, ok := spec.Requests[name]; ok && req.Cmp(lim) > 0 {
+				errs = append(errs, field.Invalid(fp.Child("requests").Key(string(name)), req.String(),
+					fmt.Sprintf("must not exceed limit %s", lim.String())))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(s.Profiles) {
+		p := s.Profiles[name]
+		fp := field.NewPath("profiles").Key(name)
+		check(&p.resourceSpec, fp)
+		check(p.InitContainers, fp.Child("initContainers"))
+		for _, cn := range sortedKeys(p.Containers) {
+			spec := p.Containers[cn]
+			check(&spec, fp.Child("containers").Key(cn))
+		}
+	}
+	for _, cn := range sortedKeys(s.Sidecars) {
+		spec := s.Sidecars[cn]
+		check(&spec, field.NewPath("sidecars").Key(cn))
+	}
+	check(s.SidecarDefaults, field.NewPath("sidecarDefaults"))
+	check(s.InitContainers, field.NewPath("initContainers"))
+
+	return errs
+}
+
+// profileName – anotacja resource-profile > label nf > "default"
+func (s *resourceProfileSet) profileName(meta *metav1.ObjectMeta) string {
+	if v := meta.Annotations[resourceProfileAnnotation]; v != "" {
+		return v
+	}
+	if nf := meta.Labels[nfLabelKey]; nf != "" {
+		if _, ok := s.Profiles[nf]; ok {
+			return nf
+		}
+	}
+	return defaultResourceProfile
+}
+
+// profileFor – profil dla Poda; nieznana anotacja => profil domyślny
+// (walidator i tak odrzuci taki obiekt)
+func (s *resourceProfileSet) profileFor(meta *metav1.ObjectMeta) resourceProfile {
+	if p, ok := s.Profiles[s.profileName(meta)]; ok {
+		return p
+	}
+	return s.Profiles[defaultResourceProfile]
+}
+
+// containerSpec wybiera rozmiar kontenera:
+//  1. nadpisanie w profilu po nazwie kontenera,
+//  2. init container: initContainers profilu / pliku,
+//  3. znany sidecar po nazwie (np. tcpdump-sidecar),
+//  4. główny kontener NF (patrz mainContainerIndex): profil,
+//  5. pozostałe kontenery: sidecarDefaults, a na końcu profil "default".
+func (s *resourceProfileSet) containerSpec(meta *metav1.ObjectMeta, c *corev1.Container, isMain, init bool) resourceSpec {
+	prof := s.profileFor(meta)
+
+	if spec, ok := prof.Containers[c.Name]; ok {
+		return spec
+	}
+	if init {
+		if prof.InitContainers != nil {
+			return *prof.InitContainers
+		}
+		if s.InitContainers != nil {
+			return *s.InitContainers
+		}
+		return s.Profiles[defaultResourceProfile].resourceSpec
+	}
+	if spec, ok := s.Sidecars[c.Name]; ok {
+		return spec
+	}
+
+	if isMain {
+		return prof.resourceSpec
+	}
+	if s.SidecarDefaults != nil {
+		return *s.SidecarDefaults
+	}
+	return s.Profiles[defaultResourceProfile].resourceSpec
+}
+
+// mainContainerIndex – kontener o nazwie równej labelowi nf, a bez niego pierwszy
+func mainContainerIndex(meta *metav1.ObjectMeta, containers []corev1.Container) int {
+	if nf := meta.Labels[nfLabelKey]; nf != "" {
+		for i, c := range containers {
+			if c.Name == nf {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// sidecarResources – pełne requests/limits dla sidecara dodawanego przez webhook
+func (s *resourceProfileSet) sidecarResources(name string) corev1.ResourceRequirements {
+	spec, ok := s.Sidecars[name]
+	if !ok {
+		if s.SidecarDefaults != nil {
+			spec = *s.SidecarDefaults
+		} else {
+			spec = s.Profiles[defaultResourceProfile].resourceSpec
+		}
+	}
+	return corev1.ResourceRequirements{
+		Requests: spec.Requests.DeepCopy(),
+		Limits:   spec.Limits.DeepCopy(),
+	}
+}
+
+// applyResourceSpec uzupełnia brakujące klucze requests/limits z profilu.
+// Wartości ustawione przez użytkownika wygrywają: dopisany request nie
+// przekroczy istniejącego limitu, a dopisany limit nie spadnie poniżej requestu.
+func applyResourceSpec(c *corev1.Container, spec resourceSpec) {
+	res := &c.Resources
+
+	for _, name := range sortedKeys(spec.Requests) {
+		if _, ok := res.Requests[name]; ok {
+			continue
+		}
+		q := spec.Requests[name].DeepCopy()
+		if lim, ok := res.Limits[name]; ok && q.Cmp(lim) > 0 {
+			q = lim.DeepCopy()
+		}
+		if res.Requests == nil {
+			res.Requests = corev1.ResourceList{}
+		}
+		res.Requests[name] = q
+	}
+
+	for _, name := range sortedKeys(spec.Limits) {
+		if _, ok := res.Limits[name]; ok {
+			continue
+		}
+		q := spec.Limits[name].DeepCopy()
+		if req, ok := res.Requests[name]; ok && q.Cmp(req) < 0 {
+			q = req.DeepCopy()
+		}
+		if res.Limits == nil {
+			res.Limits = corev1.ResourceList{}
+		}
+		res.Limits[name] = q
+	}
+}
+
+// validateResourceProfile – anotacja resource-profile musi wskazywać istniejący profil
+func validateResourceProfile(t *podTarget) field.ErrorList {
+	name := t.annotation(resourceProfileAnnotation)
+	if name == "" {
+		return nil
+	}
+	if _, ok := resourceProfiles.Profiles[name]; ok {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(
+		t.field("metadata", "annotations").Key(resourceProfileAnnotation),
+		name, sortedKeys(resourceProfiles.Profiles))}
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Anotacja service-ip: IP musi leżeć w service CIDR klastra (SERVICE_CIDRS
+// albo obiekty ServiceCIDR), nie może być zajęte przez inny Service (cache
+// z informera) i nie może się różnić od już nadanego spec.clusterIP.
+
+var (
+	// SERVICE_CIDRS – zakresy clusterIP (--service-cluster-ip-range); puste = z obiektów ServiceCIDR
+	serviceCIDRs        = getEnv("SERVICE_CIDRS", "")
+	serviceCIDRCacheTTL = getEnvDuration("SERVICE_CIDR_CACHE_TTL", 5*time.Minute)
+
+	// ustawiane w main(): indeks Service'ów po IP i źródło zakresów ServiceCIDR
+	serviceIndexer     cache.Indexer
+	serviceCIDRSources *serviceCIDRDiscovery
+)
+
+const serviceIPIndex = "serviceIP"
+
+// serviceIPIndexFunc – clusterIPs Service'u oraz IP z anotacji service-ip
+// (Service z anotacją, ale jeszcze bez clusterIP, też "rezerwuje" adres)
+func serviceIPIndexFunc(obj interface{}) ([]string, error) {
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	add := func(raw string) {
+		if ip := net.ParseIP(strings.TrimSpace(raw)); ip != nil && !seen[ip.String()] {
+			seen[ip.String()] = true
+			out = append(out, ip.String())
+		}
+	}
+	add(svc.Spec.ClusterIP)
+	for _, ip := range svc.Spec.ClusterIPs {
+		add(ip)
+	}
+	add(svc.Annotations[serviceIPAnnotation])
+	return out, nil
+}
+
+func parseCIDRList(raw string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, c := range strings.Split(raw, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", c, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// serviceCIDRDiscovery – spec.cidrs z obiektów ServiceCIDR (networking.k8s.io;
+// v1 od 1.33, wcześniej v1beta1/v1alpha1), przez klienta dynamicznego,
+// żeby nie zależeć od wersji API w client-go; wynik trzymany przez TTL
+type serviceCIDRDiscovery struct {
+	client dynamic.Interface
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	cidrs   []*net.IPNet
+	expires time.Time
+}
+
+var serviceCIDRVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+func newServiceCIDRDiscovery(client dynamic.Interface, ttl time.Duration) *serviceCIDRDiscovery {
+	return &serviceCIDRDiscovery{client: client, ttl: ttl}
+}
+
+func (d *serviceCIDRDiscovery) CIDRs(ctx context.Context) ([]*net.IPNet, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Now().Before(d.expires) {
+		return d.cidrs, nil
+	}
+
+	var cidrs []*net.IPNet
+	for _, v := range serviceCIDRVersions {
+		gvr := schema.GroupVersionResource{Group: "networking.k8s.io", Version: v, Resource: "servicecidrs"}
+		list, err := d.client.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list servicecidrs (%s): %w", v, err)
+		}
+		for _, item := range list.Items {
+			raw, _, _ := unstructured.NestedStringSlice(item.Object, "spec", "cidrs")
+			parsed, err := parseCIDRList(strings.Join(raw, ","))
+			if err != nil {
+				log.Printf("servicecidr %s: %v", item.GetName(), err)
+				continue
+			}
+			cidrs = append(cidrs, parsed...)
+		}
+		if len(list.Items) > 0 {
+			break
+		}
+	}
+
+	d.cidrs, d.expires = cidrs, time.Now().Add(d.ttl)
+	return cidrs, nil
+}
+
+// clusterServiceCIDRs – SERVICE_CIDRS, a bez niej zakresy z ServiceCIDR;
+// pusta lista = zakres nieznany (sprawdzenie CIDR jest pomijane)
+func clusterServiceCIDRs(ctx context.Context) ([]*net.IPNet, error) {
+	if strings.TrimSpace(serviceCIDRs) != "" {
+		return parseCIDRList(serviceCIDRs)
+	}
+	if serviceCIDRSources == nil {
+		return nil, nil
+	}
+	return serviceCIDRSources.CIDRs(ctx)
+}
+
+// validateServiceIP – anotacja service-ip; old != nil oznacza UPDATE
+func validateServiceIP(svc, old *corev1.Service, namespace string) field.ErrorList {
+	val := svc.Annotations[serviceIPAnnotation]
+	ipStr := strings.TrimSpace(val)
+	if ipStr == "" {
+		return nil
+	}
+	fp := field.NewPath("metadata", "annotations").Key(serviceIPAnnotation)
+
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return field.ErrorList{field.Invalid(fp, val, "not a valid IP address")}
+	}
+
+	var errs field.ErrorList
+
+	// spec.clusterIP jest niezmienne – anotacja musi się z nim zgadzać
+	if cur := svc.Spec.ClusterIP; cur != "" {
+		if curIP := net.ParseIP(cur); curIP == nil || !curIP.Equal(ip) {
+			msg := fmt.Sprintf("anotacja różni się od spec.clusterIP (%s)", cur)
+			if old != nil {
+				msg = fmt.Sprintf("spec.clusterIP (%s) jest niezmienne – anotacja service-ip nie może go zmienić", cur)
+			}
+			errs = append(errs, field.Invalid(fp, val, msg))
+		}
+	}
+
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+	cidrs, err := clusterServiceCIDRs(ctx)
+	if err != nil {
+		errs = append(errs, field.InternalError(fp, fmt.Errorf("service CIDR: %w", err)))
+	} else if len(cidrs) > 0 {
+		inside := false
+		var names []string
+		for _, n := range cidrs {
+			names = append(names, n.String())
+			if n.Contains(ip) {
+				inside = true
+			}
+		}
+		if !inside {
+			errs = append(errs, field.Invalid(fp, val,
+				fmt.Sprintf("IP spoza service CIDR klastra (%s)", strings.Join(names, ", "))))
+		}
+	}
+
+	if serviceIndexer != nil {
+		objs, err := serviceIndexer.ByIndex(serviceIPIndex, ip.String())
+		if err != nil {
+			errs = append(errs, field.InternalError(fp, fmt.Errorf("service index: %w", err)))
+		}
+		for _, o := range objs {
+			other, ok := o.(*corev1.Service)
+			if !ok || (other.Namespace == namespace && other.Name == svc.Name) {
+				continue
+			}
+			errs = append(errs, field.Duplicate(fp,
+				fmt.Sprintf("%s (używane przez Service %s/%s)", ip, other.Namespace, other.Name)))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Labele Service'u z Podów, które wybiera: nf + labele slice'a (kopiowane
+// z anotacji przez copy5gAnnotationsToLabels). Helm często tworzy Service
+// przed Podami, więc bez Podów patrzymy na template'y workloadów. Pody
+// i workloady pochodzą z cache informerów, nie z API.
+
+// serviceDerivedLabelKeys – labele przenoszone z Podów na Service
+var serviceDerivedLabelKeys = []string{
+	nfLabelKey,
+	sliceIdAnnotation,
+	sstAnnotation,
+	sdAnnotation,
+	dnnAnnotation,
+}
+
+// selectedPods – Pody (albo template'y workloadów) pasujące do selektora
+// Service'u; template dostaje nazwę workloadu
+func selectedPods(ns string, selector map[string]string) ([]corev1.PodTemplateSpec, error) {
+	if podLister == nil {
+		return nil, nil
+	}
+	sel := labels.SelectorFromSet(selector)
+
+	pods, err := podLister.Pods(ns).List(sel)
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	var out []corev1.PodTemplateSpec
+	for _, p := range pods {
+		out = append(out, corev1.PodTemplateSpec{ObjectMeta: p.ObjectMeta, Spec: p.Spec})
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+
+	// brak Podów – template'y Deploymentów i StatefulSetów
+	fromTemplate := func(name string, tpl corev1.PodTemplateSpec) {
+		if sel.Matches(labels.Set(tpl.Labels)) {
+			tpl.Name = name
+			out = append(out, tpl)
+		}
+	}
+	deps, err := deploymentLister.Deployments(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for _, d := range deps {
+		fromTemplate("deployment/"+d.Name, d.Spec.Template)
+	}
+	sts, err := statefulSetLister.StatefulSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for _, st := range sts {
+		fromTemplate("statefulset/"+st.Name, st.Spec.Template)
+	}
+	return out, nil
+}
+
+// ensureServiceNFLabels – label ustawiany tylko, gdy wszystkie wybrane Pody
+// mają tę samą wartość; labele ustawione na Service wygrywają
+func ensureServiceNFLabels(namespace string, svc *corev1.Service) {
+	if len(svc.Spec.Selector) == 0 {
+		return
+	}
+	// nf w selektorze nie wymaga pytania API
+	if nf := svc.Spec.Selector[nfLabelKey]; nf != "" && svc.Labels[nfLabelKey] == "" {
+		setLabel(&svc.ObjectMeta, nfLabelKey, nf)
+	}
+
+	missing := false
+	for _, k := range serviceDerivedLabelKeys {
+		if svc.Labels[k] == "" {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return
+	}
+
+	pods, err := selectedPods(namespace, svc.Spec.Selector)
+	if err != nil {
+		log.Printf("service %s/%s: %v", namespace, svc.Name, err)
+		return
+	}
+	if len(pods) == 0 {
+		return
+	}
+
+	for _, k := range serviceDerivedLabelKeys {
+		if svc.Labels[k] != "" {
+			continue
+		}
+		v := pods[0].Labels[k]
+		for _, p := range pods[1:] {
+			if p.Labels[k] != v {
+				v = ""
+				break
+			}
+		}
+		if v != "" {
+			setLabel(&svc.ObjectMeta, k, v)
+		}
+	}
+}
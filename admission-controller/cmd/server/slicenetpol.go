@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Izolacja slice'ów: dla każdej wartości labela 5g.kkarczmarek.dev/slice-id
+// (nadawanego przez copy5gAnnotationsToLabels) w namespace z admission
+// włączonym kontroler utrzymuje NetworkPolicy, która na sieci Poda
+// przepuszcza tylko ruch w obrębie slice'a, control plane (SBI i PFCP
+// z/do AMF/SMF/NRF), SBI od współdzielonych NF-ów (notyfikacje PCF/UDM/CHF),
+// DNS i skonfigurowany egress do współdzielonych usług (SBI UDM/PCF/CHF...,
+// MongoDB, CIDR-y np. apiservera). AMF-y slice'ów dostają osobną politykę
+// slice-n2-amf z N2 (SCTP 38412) od gNB z dowolnego adresu. Polityki nie mają
+// ownerReferences – politykę slice'a bez Podów usuwa kontroler, a resztę
+// usunięcie namespace'u. Interfejsów Multus (N3/N6) NetworkPolicy nie obejmuje.
+// Zastępuje ręczne policies/networkpolicy-*.yaml per slice.
+
+var (
+	// SLICE_NETWORK_POLICIES=true – generowanie NetworkPolicy per slice
+	sliceNetpolEnabled = getEnvBool("SLICE_NETWORK_POLICIES", false)
+	// SLICE_NETPOL_CONTROL_PLANE – NF-y control plane (label nf) wpuszczane do każdego slice'a
+	sliceNetpolControlPlane = getEnv("SLICE_NETPOL_CONTROL_PLANE", "amf,smf,nrf")
+	// SLICE_NETPOL_SHARED_NFS – NF-y (label nf) współdzielone przez slice'y, osiągalne po SBI
+	sliceNetpolSharedNFs = getEnv("SLICE_NETPOL_SHARED_NFS", "ausf,udm,udr,pcf,nssf,chf")
+	// SLICE_NETPOL_EGRESS_SELECTORS – selektory Podów w namespace (rozdzielone ';'), wszystkie porty
+	sliceNetpolEgressSelectors = getEnv("SLICE_NETPOL_EGRESS_SELECTORS", "app.kubernetes.io/name=mongodb")
+	// SLICE_NETPOL_EGRESS_CIDRS – CIDR-y poza klastrem/Podami (np. endpoint apiservera), wszystkie porty
+	sliceNetpolEgressCIDRs = getEnv("SLICE_NETPOL_EGRESS_CIDRS", "")
+	sliceNetpolResync      = getEnvDuration("SLICE_NETPOL_RESYNC", 5*time.Minute)
+)
+
+const (
+	managedByLabelKey   = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "admission-webhook"
+	componentLabelKey   = "app.kubernetes.io/component"
+	sliceNetpolPrefix   = "slice-isolation-"
+	// polityka N2 dla AMF-ów w slice'ach (nazwa spoza prefiksu slice-isolation-)
+	sliceN2NetpolName = "slice-n2-amf"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sliceNetpolName – nazwa polityki dla slice-id (DNS-1123); gdy slice-id
+// trzeba było zmienić (np. "a.b" -> "a-b"), dochodzi sufiks z hasha
+// oryginału, żeby różne slice-id nie dały tej samej nazwy
+func sliceNetpolName(sliceID string) string {
+	n := invalidNameChars.ReplaceAllString(strings.ToLower(sliceID), "-")
+	n = strings.Trim(n, "-")
+	if n == sliceID && len(n) <= 63-len(sliceNetpolPrefix) {
+		return sliceNetpolPrefix + n
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sliceID))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	if max := 63 - len(sliceNetpolPrefix) - len(suffix); len(n) > max {
+		n = strings.TrimRight(n[:max], "-")
+	}
+	return sliceNetpolPrefix + n + suffix
+}
+
+type sliceNetpolController struct {
+	clientset  kubernetes.Interface
+	pods       corelisters.PodLister
+	namespaces corelisters.NamespaceLister
+	policies   networkinglisters.NetworkPolicyLister
+	trigger    chan struct{}
+	// egress do współdzielonych usług (SLICE_NETPOL_SHARED_NFS/_EGRESS_*)
+	egress []networkingv1.NetworkPolicyEgressRule
+}
+
+// newSliceNetpolController – rejestruje informery w fabryce z main()
+// (przed factory.Start)
+func newSliceNetpolController(clientset kubernetes.Interface, factory informers.SharedInformerFactory) (*sliceNetpolController, error) {
+	egress, err := sliceNetpolSharedEgress()
+	if err != nil {
+		return nil, err
+	}
+	c := &sliceNetpolController{
+		clientset:  clientset,
+		pods:       factory.Core().V1().Pods().Lister(),
+		namespaces: factory.Core().V1().Namespaces().Lister(),
+		policies:   factory.Networking().V1().NetworkPolicies().Lister(),
+		trigger:    make(chan struct{}, 1),
+		egress:     egress,
+	}
+	kick := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.kick() },
+		UpdateFunc: func(_, _ interface{}) { c.kick() },
+		DeleteFunc: func(interface{}) { c.kick() },
+	}
+	for _, inf := range []cache.SharedIndexInformer{
+		factory.Core().V1().Pods().Informer(),
+		factory.Core().V1().Namespaces().Informer(),
+		factory.Networking().V1().NetworkPolicies().Informer(),
+	} {
+		if _, err := inf.AddEventHandler(kick); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// sliceNetpolSharedEgress – reguły egress z SLICE_NETPOL_SHARED_NFS,
+// SLICE_NETPOL_EGRESS_SELECTORS i SLICE_NETPOL_EGRESS_CIDRS
+func sliceNetpolSharedEgress() ([]networkingv1.NetworkPolicyEgressRule, error) {
+	var rules []networkingv1.NetworkPolicyEgressRule
+	if nfs := splitList(sliceNetpolSharedNFs); len(nfs) > 0 {
+		tcp := corev1.ProtocolTCP
+		port := intstr.FromInt32(sbiPort)
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{
+				PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key: nfLabelKey, Operator: metav1.LabelSelectorOpIn, Values: nfs,
+				}}},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{{Port: &port, Protocol: &tcp}},
+		})
+	}
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, raw := range strings.Split(sliceNetpolEgressSelectors, ";") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		sel, err := metav1.ParseToLabelSelector(raw)
+		if err != nil {
+			return nil, fmt.Errorf("SLICE_NETPOL_EGRESS_SELECTORS %q: %w", raw, err)
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: sel})
+	}
+	for _, raw := range splitList(sliceNetpolEgressCIDRs) {
+		_, n, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, fmt.Errorf("SLICE_NETPOL_EGRESS_CIDRS %q: %w", raw, err)
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: n.String()}})
+	}
+	if len(peers) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers})
+	}
+	return rules, nil
+}
+
+func (c *sliceNetpolController) kick() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (c *sliceNetpolController) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(sliceNetpolResync)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			log.Printf("slice network policies: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+			// seria zdarzeń (rollout) -> jeden przebieg
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func managedSliceNetpolSelector() labels.Selector {
+	return labels.SelectorFromSet(labels.Set{
+		managedByLabelKey: managedByLabelValue,
+		componentLabelKey: "slice-isolation",
+	})
+}
+
+// reconcile – polityki oczekiwane (namespace z admission, slice-id na Podach)
+// względem istniejących polityk zarządzanych przez webhook
+func (c *sliceNetpolController) reconcile() error {
+	nss, err := c.namespaces.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	desired := map[string]*networkingv1.NetworkPolicy{}
+	for _, ns := range nss {
+		if strings.ToLower(ns.Labels[admissionLabelKey]) != "true" || ns.DeletionTimestamp != nil {
+			continue
+		}
+		pods, err := c.pods.Pods(ns.Name).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		slices := map[string]bool{}
+		amf := false
+		for _, p := range pods {
+			id := p.Labels[sliceIdAnnotation]
+			if id == "" || p.DeletionTimestamp != nil ||
+				p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			slices[id] = true
+			amf = amf || p.Labels[nfLabelKey] == "amf"
+		}
+		ids := make([]string, 0, len(slices))
+		for id := range slices {
+			ids = append(ids, id)
+		}
+		// stała kolejność – przy kolizji nazw wygrywa zawsze ten sam slice
+		sort.Strings(ids)
+		for _, id := range ids {
+			np := buildSliceNetpol(ns.Name, id, c.egress)
+			key := ns.Name + "/" + np.Name
+			if prev, ok := desired[key]; ok {
+				log.Printf("slice network policies: %s: slice-id %q and %q map to the same policy, skipping %q",
+					key, prev.Labels[sliceIdAnnotation], id, id)
+				continue
+			}
+			desired[key] = np
+		}
+		if amf {
+			np := buildSliceN2Netpol(ns.Name)
+			desired[ns.Name+"/"+np.Name] = np
+		}
+	}
+
+	existing, err := c.policies.List(managedSliceNetpolSelector())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := ctxWithTimeout()
+	defer cancel()
+
+	var errs []string
+	for _, cur := range existing {
+		key := cur.Namespace + "/" + cur.Name
+		want, ok := desired[key]
+		delete(desired, key)
+		api := c.clientset.NetworkingV1().NetworkPolicies(cur.Namespace)
+		switch {
+		case !ok:
+			err = api.Delete(ctx, cur.Name, metav1.DeleteOptions{})
+			if apierrors.IsNotFound(err) {
+				err = nil
+			}
+		case !equality.Semantic.DeepEqual(cur.Spec, want.Spec) ||
+			!equality.Semantic.DeepEqual(cur.OwnerReferences, want.OwnerReferences) ||
+			!equality.Semantic.DeepEqual(cur.Labels, want.Labels):
+			upd := cur.DeepCopy()
+			upd.Spec, upd.OwnerReferences, upd.Labels = want.Spec, want.OwnerReferences, want.Labels
+			_, err = api.Update(ctx, upd, metav1.UpdateOptions{})
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	for key, np := range desired {
+		_, err := c.clientset.NetworkingV1().NetworkPolicies(np.Namespace).Create(ctx, np, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// buildSliceNetpol – polityka dla Podów slice'a: ruch wewnątrz slice'a,
+// SBI/PFCP z/do NF-ów control plane, SBI od współdzielonych NF-ów, DNS
+// i egress do współdzielonych usług
+func buildSliceNetpol(namespace, sliceID string, shared []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	port := func(p int32, proto corev1.Protocol) networkingv1.NetworkPolicyPort {
+		v := intstr.FromInt32(p)
+		return networkingv1.NetworkPolicyPort{Port: &v, Protocol: &proto}
+	}
+
+	slicePeer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{sliceIdAnnotation: sliceID}},
+	}
+	cpNFs := splitList(sliceNetpolControlPlane)
+	cpPeer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key: nfLabelKey, Operator: metav1.LabelSelectorOpIn, Values: cpNFs,
+		}}},
+	}
+	cpPorts := []networkingv1.NetworkPolicyPort{port(sbiPort, tcp), port(pfcpPort, udp)}
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{From: []networkingv1.NetworkPolicyPeer{slicePeer}},
+		{From: []networkingv1.NetworkPolicyPeer{cpPeer}, Ports: cpPorts},
+	}
+	// callbacki SBI od NF-ów współdzielonych (notyfikacje PCF/UDM/CHF do SMF/AMF)
+	if nfs := splitList(sliceNetpolSharedNFs); len(nfs) > 0 {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{{
+				PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key: nfLabelKey, Operator: metav1.LabelSelectorOpIn, Values: nfs,
+				}}},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{port(sbiPort, tcp)},
+		})
+	}
+	dnsPeer := networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+	}
+
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{To: []networkingv1.NetworkPolicyPeer{slicePeer}},
+		{To: []networkingv1.NetworkPolicyPeer{cpPeer}, Ports: cpPorts},
+		{To: []networkingv1.NetworkPolicyPeer{dnsPeer}, Ports: []networkingv1.NetworkPolicyPort{port(53, udp), port(53, tcp)}},
+	}
+	egress = append(egress, shared...)
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sliceNetpolName(sliceID),
+			Namespace: namespace,
+			Labels: map[string]string{
+				managedByLabelKey: managedByLabelValue,
+				componentLabelKey: "slice-isolation",
+				sliceIdAnnotation: sliceID,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *slicePeer.PodSelector,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress:     ingress,
+			Egress:      egress,
+		},
+	}
+}
+
+// buildSliceN2Netpol – N2 (NGAP, SCTP) do AMF-ów w slice'ach; gNB są zwykle
+// poza klastrem albo w innym namespace, więc źródło nie jest ograniczone.
+// Polityki się sumują, więc reszta ruchu AMF-a zostaje w polityce slice'a.
+func buildSliceN2Netpol(namespace string) *networkingv1.NetworkPolicy {
+	sctp := corev1.ProtocolSCTP
+	n2 := intstr.FromInt32(n2Port)
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sliceN2NetpolName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				managedByLabelKey: managedByLabelValue,
+				componentLabelKey: "slice-isolation",
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{nfLabelKey: "amf"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key: sliceIdAnnotation, Operator: metav1.LabelSelectorOpExists,
+				}},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &n2, Protocol: &sctp}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Spójność topologii slice'a: upNodes typu UPF w smfcfg.yaml muszą wskazywać
+// adres N4 UPF-a i te same DNN / S-NSSAI / pulę UE, które UPF obsługuje
+// (anotacje dnn, sst, sd, ue-pool-cidr). Bez tego błąd wychodzi dopiero jako
+// nieudana asocjacja PFCP. Sprawdzamy w obie strony:
+//   - ConfigMapa SMF względem UPF-ów (Pody + template'y workloadów) w namespace
+//     (z cache informerów, listers.go),
+//   - UPF (także zmiana adresu N4 przy UPDATE) względem ConfigMap SMF.
+//
+// UPF bez adresu N4 w anotacjach sieci nie bierze udziału w sprawdzeniu.
+
+var (
+	// SLICE_TOPOLOGY_CHECK=false – wyłącza korelację SMF <-> UPF
+	sliceTopologyCheck = getEnvBool("SLICE_TOPOLOGY_CHECK", true)
+)
+
+const smfConfigKey = "smfcfg.yaml"
+
+// upfSlice – to, co UPF deklaruje anotacjami
+type upfSlice struct {
+	Name    string // pod/x, deployment/x, ...
+	N4      net.IP
+	SliceID string
+	SST, SD string
+	DNN     string
+	UEPool  *net.IPNet
+}
+
+func upfSliceOf(name string, meta *metav1.ObjectMeta) upfSlice {
+	u := upfSlice{
+		Name:    name,
+		N4:      upfInterfaces(meta.Annotations)["n4"].IP,
+		SliceID: strings.TrimSpace(meta.Annotations[sliceIdAnnotation]),
+		SST:     strings.TrimSpace(meta.Annotations[sstAnnotation]),
+		SD:      strings.ToLower(strings.TrimSpace(meta.Annotations[sdAnnotation])),
+		DNN:     strings.TrimSpace(meta.Annotations[dnnAnnotation]),
+	}
+	if v := strings.TrimSpace(meta.Annotations[uePoolCidrAnnotation]); v != "" {
+		_, u.UEPool, _ = net.ParseCIDR(v)
+	}
+	return u
+}
+
+// sameAnnotations – te same N4, slice, DNN i pula UE
+func (u upfSlice) sameAnnotations(o upfSlice) bool {
+	return u.N4.Equal(o.N4) && u.SliceID == o.SliceID && u.SST == o.SST && u.SD == o.SD &&
+		u.DNN == o.DNN && u.UEPool.String() == o.UEPool.String()
+}
+
+func (u upfSlice) slice() string {
+	s := "sst=" + u.SST
+	if u.SD != "" {
+		s += " sd=" + u.SD
+	}
+	if u.SliceID != "" {
+		s += " (slice-id " + u.SliceID + ")"
+	}
+	return s
+}
+
+// smfUpfInfo – jeden wpis dnnUpfInfoList upNode'a (z S-NSSAI rodzica)
+type smfUpfInfo struct {
+	SST, SD string
+	DNN     string
+	Pools   []*net.IPNet
+}
+
+// smfUpNode – upNode typu UPF z userplaneInformation
+type smfUpNode struct {
+	node  cfgNode
+	IPs   []net.IP // nodeID i addr, o ile są adresami IP
+	Infos []smfUpfInfo
+}
+
+func (n smfUpNode) refers(ip net.IP) bool {
+	for _, a := range n.IPs {
+		if a.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// smfUpNodes – upNodes typu UPF z korzenia smfcfg.yaml
+func smfUpNodes(root cfgNode) []smfUpNode {
+	var out []smfUpNode
+	for _, n := range root.get("configuration").get("userplaneInformation").get("upNodes").entries() {
+		if n.get("type").value() != "UPF" {
+			continue
+		}
+		un := smfUpNode{node: n}
+		for _, key := range []string{"nodeID", "addr"} {
+			if ip := net.ParseIP(strings.TrimSpace(n.get(key).value())); ip != nil {
+				un.IPs = append(un.IPs, ip)
+			}
+		}
+		for _, s := range n.get("sNssaiUpfInfos").items() {
+			sst := strings.TrimSpace(s.get("sNssai").get("sst").value())
+			sd := strings.ToLower(strings.TrimSpace(s.get("sNssai").get("sd").value()))
+			for _, d := range s.get("dnnUpfInfoList").items() {
+				info := smfUpfInfo{SST: sst, SD: sd, DNN: strings.TrimSpace(d.get("dnn").value())}
+				for _, p := range d.get("pools").items() {
+					if _, pn, err := net.ParseCIDR(strings.TrimSpace(p.get("cidr").value())); err == nil {
+						info.Pools = append(info.Pools, pn)
+					}
+				}
+				un.Infos = append(un.Infos, info)
+			}
+		}
+		out = append(out, un)
+	}
+	return out
+}
+
+// sliceMismatches – czym UPF różni się od upNode'a SMF (pusta lista = zgodne).
+// Porównujemy tylko to, co UPF deklaruje.
+func sliceMismatches(u upfSlice, n smfUpNode) []string {
+	var out []string
+	infos := n.Infos
+	if u.DNN != "" {
+		var same []smfUpfInfo
+		for _, i := range infos {
+			if i.DNN == u.DNN {
+				same = append(same, i)
+			}
+		}
+		if len(same) == 0 {
+			return append(out, fmt.Sprintf("DNN %q UPF-a nie występuje w dnnUpfInfoList", u.DNN))
+		}
+		infos = same
+	}
+	if u.SST != "" {
+		ok := false
+		for _, i := range infos {
+			if i.SST == u.SST && (u.SD == "" || i.SD == u.SD) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			out = append(out, fmt.Sprintf("S-NSSAI UPF-a (%s) nie występuje w sNssaiUpfInfos", u.slice()))
+		}
+	}
+	if u.UEPool != nil {
+		for _, i := range infos {
+			for _, p := range i.Pools {
+				ones, _ := p.Mask.Size()
+				poolOnes, _ := u.UEPool.Mask.Size()
+				if !u.UEPool.Contains(p.IP) || ones < poolOnes {
+					out = append(out, fmt.Sprintf("pula UE %s (DNN %s) leży poza ue-pool-cidr UPF-a %s", p, i.DNN, u.UEPool))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// namespaceUpfs – UPF-y w namespace: Pody oraz template'y workloadów (z cache)
+func namespaceUpfs(ns string) ([]upfSlice, error) {
+	if podLister == nil {
+		return nil, nil
+	}
+	var out []upfSlice
+	add := func(name string, tpl *corev1.PodTemplateSpec) {
+		if !isUpfPodTemplate(tpl) {
+			return
+		}
+		if u := upfSliceOf(name, &tpl.ObjectMeta); u.N4 != nil {
+			out = append(out, u)
+		}
+	}
+
+	pods, err := podLister.Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	for _, p := range pods {
+		add("pod/"+p.Name, &corev1.PodTemplateSpec{ObjectMeta: p.ObjectMeta, Spec: p.Spec})
+	}
+	deps, err := deploymentLister.Deployments(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for _, d := range deps {
+		add("deployment/"+d.Name, &d.Spec.Template)
+	}
+	sts, err := statefulSetLister.StatefulSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for _, st := range sts {
+		add("statefulset/"+st.Name, &st.Spec.Template)
+	}
+	dss, err := daemonSetLister.DaemonSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list daemonsets: %w", err)
+	}
+	for _, ds := range dss {
+		add("daemonset/"+ds.Name, &ds.Spec.Template)
+	}
+	return out, nil
+}
+
+// smfConfig – sparsowany smfcfg.yaml z nazwą ConfigMapy
+type smfConfig struct {
+	ConfigMap string
+	Nodes     []smfUpNode
+}
+
+// namespaceSmfConfigs – ConfigMapy z smfcfg.yaml z cache (zepsuty YAML
+// pomijamy – odrzuca go walidator schematu)
+func namespaceSmfConfigs(ns string) ([]smfConfig, error) {
+	if configMapLister == nil {
+		return nil, nil
+	}
+	cms, err := configMapLister.ConfigMaps(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	var out []smfConfig
+	for _, cm := range cms {
+		data, ok := cm.Data[smfConfigKey]
+		if !ok {
+			continue
+		}
+		if root, ferr := parseNFConfig(smfConfigKey, data); ferr == nil {
+			out = append(out, smfConfig{ConfigMap: cm.Name, Nodes: smfUpNodes(root)})
+		}
+	}
+	return out, nil
+}
+
+// validateSmfTopology – upNodes UPF z ConfigMapy SMF względem UPF-ów
+// w namespace; bez UPF-ów z adresem N4 nie ma z czym porównać.
+// Niespójność z UPF-em o tym samym N4 to błąd; upNode bez UPF-a to tylko
+// ostrzeżenie (warns) – przy zmianie N4 SMF jest aktualizowany pierwszy.
+func validateSmfTopology(cm *corev1.ConfigMap, namespace string) (errs, warns field.ErrorList) {
+	data, ok := cm.Data[smfConfigKey]
+	if !ok || !sliceTopologyCheck {
+		return nil, nil
+	}
+	root, ferr := parseNFConfig(smfConfigKey, data)
+	if ferr != nil {
+		return nil, nil
+	}
+
+	upfs, err := namespaceUpfs(namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("data").Key(smfConfigKey), err)}, nil
+	}
+	if len(upfs) == 0 {
+		return nil, nil
+	}
+
+	for _, n := range smfUpNodes(root) {
+		if len(n.IPs) == 0 {
+			continue
+		}
+		var matched []upfSlice
+		for _, u := range upfs {
+			if n.refers(u.N4) {
+				matched = append(matched, u)
+			}
+		}
+		if len(matched) == 0 {
+			warns = append(warns, field.Invalid(n.node.fp.Child("nodeID"), n.node.get("nodeID").value(),
+				n.node.msg("upNode %s nie wskazuje (jeszcze) adresu N4 żadnego UPF-a w namespace (znane: %s)",
+					n.node.key, knownN4(upfs))))
+			continue
+		}
+		seen := map[string]bool{}
+		for _, u := range matched {
+			for _, m := range sliceMismatches(u, n) {
+				if seen[m] {
+					continue
+				}
+				seen[m] = true
+				errs = append(errs, field.Invalid(n.node.fp, n.node.key,
+					n.node.msg("upNode %s niespójny z %s (N4 %s): %s", n.node.key, u.Name, u.N4, m)))
+			}
+		}
+	}
+	return errs, warns
+}
+
+func knownN4(upfs []upfSlice) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, u := range upfs {
+		if s := u.N4.String(); !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+// validateUpfTopology – UPF względem upNodes SMF; old != nil oznacza UPDATE
+// (zmiana adresu N4, na który wskazuje SMF, zostawiłaby SMF bez UPF-a).
+// UPDATE bez zmian w anotacjach N4/slice'a nie jest sprawdzany ponownie.
+func validateUpfTopology(t, old *podTarget, namespace string) field.ErrorList {
+	if !sliceTopologyCheck {
+		return nil
+	}
+	u := upfSliceOf(strings.ToLower(t.Kind)+"/"+t.Owner.Name, t.Meta)
+	var oldN4 net.IP
+	if old != nil {
+		prev := upfSliceOf(u.Name, old.Meta)
+		if u.sameAnnotations(prev) {
+			return nil
+		}
+		oldN4 = prev.N4
+	}
+	if u.N4 == nil && oldN4 == nil {
+		return nil
+	}
+
+	fp := t.field("metadata", "annotations")
+	smfs, err := namespaceSmfConfigs(namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(fp, err)}
+	}
+
+	var errs field.ErrorList
+	for _, s := range smfs {
+		for _, n := range s.Nodes {
+			ref := fmt.Sprintf("ConfigMap %s, upNode %s (line %d)", s.ConfigMap, n.node.key, n.node.line)
+			switch {
+			case u.N4 != nil && n.refers(u.N4):
+				for _, m := range sliceMismatches(u, n) {
+					errs = append(errs, field.Forbidden(fp, fmt.Sprintf("UPF (N4 %s) niespójny z %s: %s", u.N4, ref, m)))
+				}
+			case oldN4 != nil && n.refers(oldN4) && !oldN4.Equal(u.N4):
+				errs = append(errs, field.Forbidden(fp,
+					fmt.Sprintf("zmiana adresu N4 z %s zostawia %s bez UPF-a – najpierw zaktualizuj konfigurację SMF", oldN4, ref)))
+			}
+		}
+	}
+	return errs
+}
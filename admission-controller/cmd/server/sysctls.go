@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Sysctle UPF: forwarding / rp_filter / MTU ustawiane centralnie przez webhook
+// (spec.securityContext.sysctls). Poza UPF dozwolony jest tylko "safe set"
+// Kubernetesa. Sysctle UPF (ip_forward, rp_filter, ...) są spoza safe setu,
+// więc kubelet odrzuca Pod (SysctlForbidden), dopóki węzły UPF nie mają
+// --allowed-unsafe-sysctls – dlatego oba env są domyślnie puste (opt-in).
+
+var (
+	// UPF_SYSCTLS – domyślne sysctle dla każdego UPF ("nazwa=wartość,...")
+	upfSysctls = getEnv("UPF_SYSCTLS", "")
+	// UPF_ALLOWED_SYSCTLS – allowlista dla UPF ponad safe set; "*" zastępuje
+	// segment (np. nazwę interfejsu); ma odpowiadać --allowed-unsafe-sysctls
+	upfAllowedSysctls = getEnv("UPF_ALLOWED_SYSCTLS", "")
+
+	// 5g.kkarczmarek.dev/sysctls – dodatkowe sysctle per UPF, np. "net.ipv4.conf.n6.rp_filter=0"
+	sysctlsAnnotation = "5g.kkarczmarek.dev/sysctls"
+
+	sysctlNameRegex = regexp.MustCompile(`^[a-z0-9_]+(\.[a-zA-Z0-9_@-]+)+$`)
+)
+
+// safeSysctls – namespaced sysctle uznawane przez kubelet za bezpieczne
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+}
+
+// parseSysctls – "a.b=1,c.d=0" -> lista w kolejności wejścia
+func parseSysctls(raw string) ([]corev1.Sysctl, error) {
+	var out []corev1.Sysctl
+	for _, e := range strings.Split(raw, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(e, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid sysctl %q, expected name=value", e)
+		}
+		if !sysctlNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid sysctl name %q", name)
+		}
+		out = append(out, corev1.Sysctl{Name: name, Value: value})
+	}
+	return out, nil
+}
+
+// isUpfSysctlAllowed – dopasowanie do UPF_ALLOWED_SYSCTLS segment po segmencie
+func isUpfSysctlAllowed(name string) bool {
+	for _, pat := range strings.Split(upfAllowedSysctls, ",") {
+		pat = strings.TrimSpace(pat)
+		if pat == "" {
+			continue
+		}
+		// path.Match: "*" nie przechodzi przez "/", więc kropki zamieniamy na "/"
+		ok, err := path.Match(strings.ReplaceAll(pat, ".", "/"), strings.ReplaceAll(name, ".", "/"))
+		if err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// upfSysctlsFor – UPF_SYSCTLS + anotacja (anotacja nadpisuje domyślne wartości)
+func upfSysctlsFor(ann map[string]string) ([]corev1.Sysctl, error) {
+	defaults, err := parseSysctls(upfSysctls)
+	if err != nil {
+		return nil, fmt.Errorf("UPF_SYSCTLS: %w", err)
+	}
+	extra, err := parseSysctls(ann[sysctlsAnnotation])
+	if err != nil {
+		return nil, err
+	}
+
+	out := []corev1.Sysctl{}
+	idx := map[string]int{}
+	for _, s := range append(defaults, extra...) {
+		if i, ok := idx[s.Name]; ok {
+			out[i] = s
+			continue
+		}
+		idx[s.Name] = len(out)
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// ensureUpfSysctls dopisuje sysctle UPF do spec.securityContext.sysctls;
+// sysctl ustawiony jawnie w spec wygrywa, niedozwolone są pomijane
+// (walidator odrzuci anotację z takim wpisem)
+func ensureUpfSysctls(spec *corev1.PodSpec, ann map[string]string) {
+	want, err := upfSysctlsFor(ann)
+	if err != nil || len(want) == 0 {
+		return
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	sc := spec.SecurityContext
+
+	for _, s := range want {
+		if !isUpfSysctlAllowed(s.Name) && !safeSysctls[s.Name] {
+			continue
+		}
+		found := false
+		for _, cur := range sc.Sysctls {
+			if cur.Name == s.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sc.Sysctls = append(sc.Sysctls, s)
+		}
+	}
+}
+
+// validateSysctls – safe set wszędzie, allowlista UPF tylko dla UPF
+func validateSysctls(t *podTarget) field.ErrorList {
+	var errs field.ErrorList
+	upf := isUpfTarget(t)
+
+	if raw := t.annotation(sysctlsAnnotation); raw != "" {
+		afp := t.field("metadata", "annotations").Key(sysctlsAnnotation)
+		if !upf {
+			errs = append(errs, field.Forbidden(afp, "anotacja sysctls jest dozwolona tylko dla UPF"))
+		} else if list, err := parseSysctls(raw); err != nil {
+			errs = append(errs, field.Invalid(afp, raw, err.Error()))
+		} else {
+			for _, s := range list {
+				if !isUpfSysctlAllowed(s.Name) && !safeSysctls[s.Name] {
+					errs = append(errs, field.Forbidden(afp,
+						fmt.Sprintf("sysctl %q nie jest na liście UPF_ALLOWED_SYSCTLS", s.Name)))
+				}
+			}
+		}
+	}
+
+	if t.Spec.SecurityContext == nil {
+		return errs
+	}
+	fp := t.field("spec", "securityContext", "sysctls")
+	for i, s := range t.Spec.SecurityContext.Sysctls {
+		if safeSysctls[s.Name] || (upf && isUpfSysctlAllowed(s.Name)) {
+			continue
+		}
+		msg := fmt.Sprintf("sysctl %q jest niebezpieczny – poza UPF dozwolone są tylko bezpieczne sysctle", s.Name)
+		if upf {
+			msg = fmt.Sprintf("sysctl %q nie jest na liście UPF_ALLOWED_SYSCTLS", s.Name)
+		}
+		errs = append(errs, field.Forbidden(fp.Index(i).Child("name"), msg))
+	}
+	return errs
+}
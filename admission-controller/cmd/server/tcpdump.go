@@ -0,0 +1,634 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// konfiguracja sidecara per Pod (anotacje)
+	tcpdumpInterfaceAnnotation  = "5g.kkarczmarek.dev/tcpdump-interface"
+	tcpdumpFilterAnnotation     = "5g.kkarczmarek.dev/tcpdump-filter"
+	tcpdumpSnaplenAnnotation    = "5g.kkarczmarek.dev/tcpdump-snaplen"
+	tcpdumpRotateSizeAnnotation = "5g.kkarczmarek.dev/tcpdump-rotate-size"
+	tcpdumpMaxFilesAnnotation   = "5g.kkarczmarek.dev/tcpdump-max-files"
+	tcpdumpPresetAnnotation     = "5g.kkarczmarek.dev/tcpdump-preset"
+	// tcpdump-export=true – sidecar pcap-server z API do pobierania plików
+	tcpdumpExportAnnotation = "5g.kkarczmarek.dev/tcpdump-export"
+	// tcpdump-volume=pvc + tcpdump-pvc=<claim> – pcapy na PVC zamiast emptyDir
+	tcpdumpVolumeAnnotation = "5g.kkarczmarek.dev/tcpdump-volume"
+	tcpdumpClaimAnnotation  = "5g.kkarczmarek.dev/tcpdump-pvc"
+
+	// wartości domyślne i limity (env)
+	tcpdumpDefaultRotateMB = getEnvInt("TCPDUMP_ROTATE_SIZE_MB", 100)
+	tcpdumpDefaultMaxFiles = getEnvInt("TCPDUMP_MAX_FILES", 5)
+	tcpdumpMaxTotalMB      = getEnvInt("TCPDUMP_MAX_TOTAL_MB", 2048)
+
+	// obraz webhooka uruchamiany z -mode=pcap-server (tryb dostępny od 0.3.0)
+	// i -mode=pcap-retention
+	pcapServerImage = getEnv("PCAP_SERVER_IMAGE", "ghcr.io/kkarczmarek/admission-webhook:0.3.0")
+	// TCPDUMP_PVC_RETENTION – katalogi Podów na PVC bez zmian dłużej niż tyle
+	// usuwa init container pcap-retention; 0 = bez retencji
+	tcpdumpPVCRetention = getEnvDuration("TCPDUMP_PVC_RETENTION", 7*24*time.Hour)
+
+	tcpdumpIfaceRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.@-]{0,14}$`)
+)
+
+const (
+	tcpdumpDataPath    = "/data"
+	tcpdumpFileName    = "trace.pcap"
+	tcpdumpMaxSnaplen  = 262144
+	tcpdumpMaxFilter   = 512
+	tcpdumpMaxFilesCap = 100
+
+	pcapServerContainerName = "pcap-server"
+	pcapRetentionName       = "pcap-retention"
+	pcapServerAddr          = "127.0.0.1:8090"
+
+	tcpdumpVolumeEmptyDir = "emptydir"
+	tcpdumpVolumePVC      = "pvc"
+)
+
+// tcpdumpConfig – parametry sidecara po sparsowaniu anotacji
+type tcpdumpConfig struct {
+	Interface string
+	Filter    string
+	Snaplen   int // 0 = domyślny snaplen tcpdumpa
+	RotateMB  int
+	MaxFiles  int
+
+	Export    bool
+	ClaimName string // != "" => wolumen z PVC, pliki w podkatalogu <nazwa Poda>
+}
+
+func isTcpdumpEnabled(ann map[string]string) bool {
+	if ann == nil {
+		return false
+	}
+	v := strings.ToLower(ann[tcpdumpEnabledAnnotation])
+	return v == "true" || v == "1" || v == "yes" || v == "on"
+}
+
+// parseTcpdumpConfig czyta anotacje tcpdump-*; fp wskazuje metadata.annotations
+func parseTcpdumpConfig(ann map[string]string, fp *field.Path) (tcpdumpConfig, field.ErrorList) {
+	cfg := tcpdumpConfig{
+		Interface: "any",
+		RotateMB:  tcpdumpDefaultRotateMB,
+		MaxFiles:  tcpdumpDefaultMaxFiles,
+	}
+	var errs field.ErrorList
+
+	// preset ustawia interfejs + filtr; jawne tcpdump-interface/-filter wygrywają
+	if v := strings.ToLower(strings.TrimSpace(ann[tcpdumpPresetAnnotation])); v != "" {
+		iface, filter, err := expandCapturePreset(v, ann)
+		if err != nil {
+			errs = append(errs, field.Invalid(fp.Key(tcpdumpPresetAnnotation), v, err.Error()))
+		} else {
+			cfg.Interface, cfg.Filter = iface, filter
+		}
+	}
+
+	if v := strings.TrimSpace(ann[tcpdumpInterfaceAnnotation]); v != "" {
+		if !tcpdumpIfaceRegex.MatchString(v) {
+			errs = append(errs, field.Invalid(fp.Key(tcpdumpInterfaceAnnotation), v,
+				"must be 'any' or a network interface name (max 15 chars: letters, digits, '_', '.', '@', '-')"))
+		} else {
+			cfg.Interface = v
+		}
+	}
+
+	if v := strings.TrimSpace(ann[tcpdumpFilterAnnotation]); v != "" {
+		if err := validateBPFFilter(v); err != nil {
+			errs = append(errs, field.Invalid(fp.Key(tcpdumpFilterAnnotation), v, err.Error()))
+		} else {
+			cfg.Filter = v
+		}
+	}
+
+	intAnno := func(key string, min, max int, dst *int) {
+		v := strings.TrimSpace(ann[key])
+		if v == "" {
+			return
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			errs = append(errs, field.Invalid(fp.Key(key), v, fmt.Sprintf("must be an integer in range %d-%d", min, max)))
+			return
+		}
+		*dst = n
+	}
+	intAnno(tcpdumpSnaplenAnnotation, 0, tcpdumpMaxSnaplen, &cfg.Snaplen)
+	intAnno(tcpdumpRotateSizeAnnotation, 1, tcpdumpMaxTotalMB, &cfg.RotateMB)
+	intAnno(tcpdumpMaxFilesAnnotation, 1, tcpdumpMaxFilesCap, &cfg.MaxFiles)
+
+	if v := strings.ToLower(strings.TrimSpace(ann[tcpdumpExportAnnotation])); v != "" {
+		cfg.Export = v == "true" || v == "1" || v == "yes" || v == "on"
+	}
+
+	claim := strings.TrimSpace(ann[tcpdumpClaimAnnotation])
+	switch mode := strings.ToLower(strings.TrimSpace(ann[tcpdumpVolumeAnnotation])); mode {
+	case "", tcpdumpVolumeEmptyDir:
+		if claim != "" {
+			errs = append(errs, field.Invalid(fp.Key(tcpdumpClaimAnnotation), claim,
+				fmt.Sprintf("requires %s=%s", tcpdumpVolumeAnnotation, tcpdumpVolumePVC)))
+		}
+	case tcpdumpVolumePVC:
+		if claim == "" {
+			errs = append(errs, field.Required(fp.Key(tcpdumpClaimAnnotation),
+				fmt.Sprintf("PVC name is required when %s=%s", tcpdumpVolumeAnnotation, tcpdumpVolumePVC)))
+		} else if msgs := validation.IsDNS1123Subdomain(claim); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(fp.Key(tcpdumpClaimAnnotation), claim, strings.Join(msgs, "; ")))
+		} else {
+			cfg.ClaimName = claim
+		}
+	default:
+		errs = append(errs, field.NotSupported(fp.Key(tcpdumpVolumeAnnotation), mode,
+			[]string{"emptyDir", tcpdumpVolumePVC}))
+	}
+
+	if total := cfg.RotateMB * cfg.MaxFiles; total > tcpdumpMaxTotalMB {
+		errs = append(errs, field.Invalid(fp.Key(tcpdumpRotateSizeAnnotation),
+			fmt.Sprintf("%dMB x %d files", cfg.RotateMB, cfg.MaxFiles),
+			fmt.Sprintf("total capture size %dMB exceeds limit %dMB (TCPDUMP_MAX_TOTAL_MB)", total, tcpdumpMaxTotalMB)))
+	}
+
+	return cfg, errs
+}
+
+// args – argumenty tcpdumpa: ring buffer -C/-W w /data, filtr jako jeden
+// argument na końcu (bez powłoki, więc bez interpretacji znaków specjalnych)
+func (c tcpdumpConfig) args() []string {
+	args := []string{
+		"-i", c.Interface,
+		"-n",
+		"-w", tcpdumpDataPath + "/" + tcpdumpFileName,
+		"-C", strconv.Itoa(c.RotateMB),
+		"-W", strconv.Itoa(c.MaxFiles),
+	}
+	if c.Snaplen > 0 {
+		args = append(args, "-s", strconv.Itoa(c.Snaplen))
+	}
+	if c.Filter != "" {
+		args = append(args, c.Filter)
+	}
+	return args
+}
+
+// volumeSizeLimit – rozmiar ring buffera + 10% zapasu na bieżący plik
+func (c tcpdumpConfig) volumeSizeLimit() resource.Quantity {
+	mb := int64(c.RotateMB*c.MaxFiles) * 11 / 10
+	if mb < 1 {
+		mb = 1
+	}
+	return *resource.NewQuantity(mb*1024*1024, resource.BinarySI)
+}
+
+// --------- filtr BPF ---------
+
+var (
+	bpfKeywords = map[string]bool{
+		"host": true, "net": true, "port": true, "portrange": true, "mask": true,
+		"src": true, "dst": true, "gateway": true, "proto": true,
+		"and": true, "or": true, "not": true,
+		"ip": true, "ip6": true, "arp": true, "rarp": true, "ether": true, "vlan": true, "mpls": true,
+		"tcp": true, "udp": true, "sctp": true, "icmp": true, "icmp6": true, "igmp": true,
+		"broadcast": true, "multicast": true, "less": true, "greater": true, "len": true,
+		"inbound": true, "outbound": true,
+	}
+	bpfNumberRegex = regexp.MustCompile(`^(0x[0-9a-fA-F]+|[0-9]+)(-[0-9]+)?$`)
+	bpfMACRegex    = regexp.MustCompile(`^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$`)
+	// operatory i nawiasy wydzielane z tekstu przed podziałem na słowa
+	bpfOperatorRegex = regexp.MustCompile(`(&&|\|\||!=|<=|>=|==|[()!<>=\[\]&|+*])`)
+)
+
+// validateBPFFilter – ścisła walidacja filtra: tylko słowa kluczowe pcap-filter,
+// liczby, adresy IP/CIDR/MAC i operatory, zbalansowane nawiasy, bez opcji ("-x")
+func validateBPFFilter(filter string) error {
+	if len(filter) > tcpdumpMaxFilter {
+		return fmt.Errorf("filter longer than %d characters", tcpdumpMaxFilter)
+	}
+	for _, r := range filter {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("filter contains non-printable or non-ASCII characters")
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(filter), "-") {
+		return fmt.Errorf("filter must not start with '-'")
+	}
+
+	depth := 0
+	spaced := bpfOperatorRegex.ReplaceAllString(filter, " $1 ")
+	for _, tok := range strings.Fields(spaced) {
+		switch tok {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unbalanced parentheses")
+			}
+			continue
+		}
+		if bpfOperatorRegex.FindString(tok) == tok {
+			continue
+		}
+		if !isBPFOperand(tok) {
+			return fmt.Errorf("unsupported token %q in filter", tok)
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced parentheses")
+	}
+	return nil
+}
+
+func isBPFOperand(tok string) bool {
+	if bpfKeywords[strings.ToLower(tok)] {
+		return true
+	}
+	if bpfNumberRegex.MatchString(tok) || bpfMACRegex.MatchString(tok) {
+		return true
+	}
+	if net.ParseIP(tok) != nil {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(tok); err == nil {
+		return true
+	}
+	return false
+}
+
+// --------- presety przechwytywania ---------
+
+const (
+	capturePresetPFCP     = "pfcp"
+	capturePresetGTPU     = "gtpu"
+	capturePresetN6UEPool = "n6-ue-pool"
+	capturePresetAll      = "all"
+)
+
+var capturePresets = []string{capturePresetPFCP, capturePresetGTPU, capturePresetN6UEPool, capturePresetAll}
+
+// upfInterface – interfejs płaszczyzny (n3/n4/n6) znaleziony w anotacjach Poda
+type upfInterface struct {
+	Name string
+	IP   net.IP
+}
+
+// expandCapturePreset zamienia preset na interfejs + filtr BPF zawężony do
+// adresów tego Poda (networks, ue-pool-cidr, n6-cidr):
+//
+//	pfcp       – N4, udp port 8805
+//	gtpu       – N3, udp port 2152
+//	n6-ue-pool – N6, ruch z/do puli UE (ue-pool-cidr)
+//	all        – wszystkie interfejsy, suma powyższych
+func expandCapturePreset(preset string, ann map[string]string) (string, string, error) {
+	ifaces := upfInterfaces(ann)
+
+	uePool := strings.TrimSpace(ann[uePoolCidrAnnotation])
+	if uePool != "" {
+		if _, _, err := net.ParseCIDR(uePool); err != nil {
+			return "", "", fmt.Errorf("preset %q needs a valid %s, got %q", preset, uePoolCidrAnnotation, uePool)
+		}
+	}
+
+	// udp port X [and host <IP interfejsu>]
+	portFilter := func(plane string, port int32) string {
+		f := fmt.Sprintf("udp port %d", port)
+		if i, ok := ifaces[plane]; ok && i.IP != nil {
+			f += " and host " + i.IP.String()
+		}
+		return f
+	}
+	ifaceFor := func(plane string) string {
+		if i, ok := ifaces[plane]; ok && i.Name != "" {
+			return i.Name
+		}
+		return "any"
+	}
+
+	var iface, filter string
+	switch preset {
+	case capturePresetPFCP:
+		iface, filter = ifaceFor("n4"), portFilter("n4", pfcpPort)
+	case capturePresetGTPU:
+		iface, filter = ifaceFor("n3"), portFilter("n3", gtpuPort)
+	case capturePresetN6UEPool:
+		if uePool == "" {
+			return "", "", fmt.Errorf("preset %q requires annotation %s", preset, uePoolCidrAnnotation)
+		}
+		iface, filter = ifaceFor("n6"), "net "+uePool
+	case capturePresetAll:
+		parts := []string{portFilter("n4", pfcpPort), portFilter("n3", gtpuPort)}
+		if uePool != "" {
+			parts = append(parts, "net "+uePool)
+		}
+		iface, filter = "any", "("+strings.Join(parts, ") or (")+")"
+	default:
+		return "", "", fmt.Errorf("unknown preset, supported: %s", strings.Join(capturePresets, ", "))
+	}
+
+	if !tcpdumpIfaceRegex.MatchString(iface) {
+		return "", "", fmt.Errorf("derived interface %q is not a valid interface name", iface)
+	}
+	if err := validateBPFFilter(filter); err != nil {
+		return "", "", fmt.Errorf("derived filter %q: %v", filter, err)
+	}
+	return iface, filter, nil
+}
+
+// upfInterfaces – interfejsy n3/n4/n6 z anotacji k8s.v1.cni.cncf.io/networks
+// (JSON z polami interface/ips) oraz 5g.kkarczmarek.dev/networks ("n3-net@IP/maska").
+// Wpis, którego nazwa nie wskazuje płaszczyzny, a IP leży w n6-cidr, traktujemy jako N6.
+func upfInterfaces(ann map[string]string) map[string]upfInterface {
+	out := map[string]upfInterface{}
+
+	var n6Net *net.IPNet
+	if v := strings.TrimSpace(ann[n6CidrAnnotation]); v != "" {
+		_, n6Net, _ = net.ParseCIDR(v)
+	}
+
+	add := func(name, iface, cidr string) {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			ip = nil
+		}
+		plane := planeOf(iface)
+		if plane == "" {
+			plane = planeOf(name)
+		}
+		if plane == "" && ip != nil && n6Net != nil && n6Net.Contains(ip) {
+			plane = "n6"
+		}
+		if plane == "" {
+			return
+		}
+		cur := out[plane]
+		if cur.Name == "" {
+			cur.Name = iface
+		}
+		if cur.IP == nil {
+			cur.IP = ip
+		}
+		out[plane] = cur
+	}
+
+	// Multus: jawne nazwy interfejsów w Podzie
+	var cni []struct {
+		Name      string   `json:"name"`
+		Interface string   `json:"interface"`
+		IPs       []string `json:"ips"`
+	}
+	if raw := strings.TrimSpace(ann[cniNetworksAnnotation]); strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &cni); err == nil {
+			for _, n := range cni {
+				ip := ""
+				if len(n.IPs) > 0 {
+					ip = n.IPs[0]
+				}
+				add(n.Name, n.Interface, ip)
+			}
+		}
+	}
+
+	// 5g.kkarczmarek.dev/networks: nazwa interfejsu = płaszczyzna (konwencja chartów free5gc)
+	for _, e := range strings.Split(ann[upfNetworksAnnotation], ",") {
+		parts := strings.SplitN(strings.TrimSpace(e), "@", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		iface := planeOf(parts[0])
+		add(parts[0], iface, parts[1])
+	}
+
+	return out
+}
+
+// planeOf – "n3", "n4" albo "n6" na podstawie nazwy sieci/interfejsu
+// (np. "n3", "n3-net", "n4network-free5gc-upf"), inaczej ""
+func planeOf(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range []string{"n3", "n4", "n6"} {
+		if strings.HasPrefix(name, p) && (len(name) == 2 || !isDigit(name[2])) {
+			return p
+		}
+	}
+	return ""
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// --------- MUTATING / VALIDATING ---------
+
+// injectTcpdumpSidecar: wspólna funkcja dla Poda i Template. Nie dubluje
+// kontenera ani volume przy ponownym wywołaniu webhooka, a przy UPDATE
+// odświeża argumenty istniejącego sidecara wg aktualnych anotacji.
+// Niepoprawna konfiguracja => brak zmian (odrzuci ją walidator).
+func injectTcpdumpSidecar(spec *corev1.PodSpec, ann map[string]string) {
+	cfg, errs := parseTcpdumpConfig(ann, field.NewPath("metadata", "annotations"))
+	if len(errs) > 0 {
+		return
+	}
+
+	upsertSidecar(spec, buildTcpdumpContainer(ann, cfg))
+	if cfg.Export {
+		upsertSidecar(spec, buildPcapServerContainer())
+	}
+	if cfg.ClaimName != "" && tcpdumpPVCRetention > 0 {
+		upsertPcapRetention(spec)
+	}
+
+	// volume pod /data: emptyDir z limitem rozmiaru ring buffera albo PVC
+	source := cfg.volumeSource()
+	for i := range spec.Volumes {
+		v := &spec.Volumes[i]
+		if v.Name != tcpdumpVolumeName {
+			continue
+		}
+		// wolumen zdefiniowany przez użytkownika innego typu zostawiamy
+		if v.EmptyDir != nil || v.PersistentVolumeClaim != nil {
+			v.VolumeSource = source
+		}
+		return
+	}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:         tcpdumpVolumeName,
+		VolumeSource: source,
+	})
+}
+
+// upsertSidecar dodaje kontener albo odświeża Args/Env/VolumeMounts istniejącego
+func upsertSidecar(spec *corev1.PodSpec, c corev1.Container) {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == c.Name {
+			spec.Containers[i].Args = c.Args
+			spec.Containers[i].Env = c.Env
+			spec.Containers[i].VolumeMounts = c.VolumeMounts
+			return
+		}
+	}
+	spec.Containers = append(spec.Containers, c)
+}
+
+func (c tcpdumpConfig) volumeSource() corev1.VolumeSource {
+	if c.ClaimName != "" {
+		return corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: c.ClaimName},
+		}
+	}
+	sizeLimit := c.volumeSizeLimit()
+	return corev1.VolumeSource{
+		EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &sizeLimit},
+	}
+}
+
+// sliceEnvVars – parametry slice'a UPF z anotacji Poda (sidecar tcpdump, kontenery debug)
+func sliceEnvVars(ann map[string]string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "UPF_SLICE_ID", Value: ann[sliceIdAnnotation]},
+		{Name: "UPF_SST", Value: ann[sstAnnotation]},
+		{Name: "UPF_SD", Value: ann[sdAnnotation]},
+		{Name: "UPF_DNN", Value: ann[dnnAnnotation]},
+		{Name: "UPF_UE_POOL_CIDR", Value: ann[uePoolCidrAnnotation]},
+		{Name: "UPF_N6_CIDR", Value: ann[n6CidrAnnotation]},
+	}
+}
+
+func buildTcpdumpContainer(ann map[string]string, cfg tcpdumpConfig) corev1.Container {
+	envs := sliceEnvVars(ann)
+
+	mount := corev1.VolumeMount{
+		Name:      tcpdumpVolumeName,
+		MountPath: tcpdumpDataPath,
+	}
+	// na PVC współdzielonym przez repliki każdy Pod pisze do własnego katalogu
+	if cfg.ClaimName != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		})
+		mount.SubPathExpr = "$(POD_NAME)"
+	}
+
+	return corev1.Container{
+		Name:  tcpdumpContainerName,
+		Image: tcpdumpImage,
+		Args:  cfg.args(),
+		Env:   envs,
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: boolPtr(false),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+				Add:  []corev1.Capability{"NET_ADMIN", "NET_RAW"},
+			},
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		Resources:    resourceProfiles.sidecarResources(tcpdumpContainerName),
+		VolumeMounts: []corev1.VolumeMount{mount},
+	}
+}
+
+// buildPcapServerContainer – webhook w trybie pcap-server, wolumen tylko do odczytu
+// (na PVC widzi katalogi wszystkich Podów, także tych sprzed restartu)
+func buildPcapServerContainer() corev1.Container {
+	return corev1.Container{
+		Name:  pcapServerContainerName,
+		Image: pcapServerImage,
+		Args: []string{
+			"-mode=pcap-server",
+			"-pcap-addr=" + pcapServerAddr,
+			"-pcap-dir=" + tcpdumpDataPath,
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: boolPtr(false),
+			RunAsNonRoot:             boolPtr(true),
+			ReadOnlyRootFilesystem:   boolPtr(true),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		Resources: resourceProfiles.sidecarResources(pcapServerContainerName),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      tcpdumpVolumeName,
+				MountPath: tcpdumpDataPath,
+				ReadOnly:  true,
+			},
+		},
+	}
+}
+
+// upsertPcapRetention – init container pcap-retention (webhook w trybie
+// -mode=pcap-retention) na całym PVC, bez subPath: usuwa katalogi Podów
+// bezczynne dłużej niż TCPDUMP_PVC_RETENTION, z pominięciem własnego.
+// Działa jako root, bo pliki zapisuje tcpdump (root) – bez żadnych capabilities.
+func upsertPcapRetention(spec *corev1.PodSpec) {
+	c := corev1.Container{
+		Name:  pcapRetentionName,
+		Image: pcapServerImage,
+		Args: []string{
+			"-mode=pcap-retention",
+			"-pcap-dir=" + tcpdumpDataPath,
+			"-pcap-retention=" + tcpdumpPVCRetention.String(),
+			"-pcap-keep=$(POD_NAME)",
+		},
+		Env: []corev1.EnvVar{{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		}},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: boolPtr(false),
+			RunAsNonRoot:             boolPtr(false),
+			RunAsUser:                int64Ptr(0),
+			ReadOnlyRootFilesystem:   boolPtr(true),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		},
+		Resources:    resourceProfiles.sidecarResources(pcapRetentionName),
+		VolumeMounts: []corev1.VolumeMount{{Name: tcpdumpVolumeName, MountPath: tcpdumpDataPath}},
+	}
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == c.Name {
+			spec.InitContainers[i].Args = c.Args
+			spec.InitContainers[i].Env = c.Env
+			spec.InitContainers[i].VolumeMounts = c.VolumeMounts
+			return
+		}
+	}
+	spec.InitContainers = append(spec.InitContainers, c)
+}
+
+// validateTcpdump – anotacje tcpdump-* muszą być poprawne, gdy sidecar jest włączony
+func validateTcpdump(t *podTarget) field.ErrorList {
+	if !isTcpdumpEnabled(t.Meta.Annotations) {
+		return nil
+	}
+	_, errs := parseTcpdumpConfig(t.Meta.Annotations, t.field("metadata", "annotations"))
+	return errs
+}
@@ -3,7 +3,9 @@ module github.com/kkarczmarek/kubernetes-free5gc/admission-controller
 go 1.22
 
 require (
+    gopkg.in/yaml.v3 v3.0.1
     k8s.io/api v0.30.2
     k8s.io/apimachinery v0.30.2
     k8s.io/client-go v0.30.2
+    sigs.k8s.io/yaml v1.3.0
 )
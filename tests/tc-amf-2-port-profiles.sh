@@ -0,0 +1,100 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-AMF-2] Profile portów NF (AMF N2/SCTP + SBI) i blokada N2 po TCP =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+divider
+
+# pod <name> <nf> <ports-yaml> – server-side dry-run
+pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: $2
+spec:
+  containers:
+  - name: $2
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+$3
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: AMF bez portów – webhook dopisuje n2 (SCTP 38412) i sbi
+# -------------------------------------------------------------------
+log "==[TC-AMF-2] Krok 1: AMF bez portów – oczekuję n2/38412/SCTP i sbi =="
+PORTS=$(pod amf-ports amf "" | jq -c '.spec.containers[0].ports')
+log "  -> ports: ${PORTS}"
+if echo "$PORTS" | jq -e 'any(.[]; .name=="n2" and .containerPort==38412 and .protocol=="SCTP")' >/dev/null \
+  && echo "$PORTS" | jq -e 'any(.[]; .name=="sbi")' >/dev/null; then
+  log "[OK] AMF dostał porty z profilu NF."
+else
+  log "[BŁĄD] brak portów n2/sbi z profilu AMF!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: SMF – PFCP po UDP
+# -------------------------------------------------------------------
+log "==[TC-AMF-2] Krok 2: SMF bez portów – oczekuję pfcp/8805/UDP =="
+PORTS=$(pod smf-ports smf "" | jq -c '.spec.containers[0].ports')
+log "  -> ports: ${PORTS}"
+if echo "$PORTS" | jq -e 'any(.[]; .name=="pfcp" and .containerPort==8805 and .protocol=="UDP")' >/dev/null; then
+  log "[OK] SMF dostał port PFCP."
+else
+  log "[BŁĄD] brak portu PFCP w SMF!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: AMF z N2 zadeklarowanym jako TCP – DENY
+# -------------------------------------------------------------------
+log "==[TC-AMF-2] Krok 3: AMF z 38412/TCP – oczekuję DENY =="
+if pod amf-n2-tcp amf '    ports:
+    - name: ngap
+      containerPort: 38412
+      protocol: TCP' >/dev/null 2>&1; then
+  log "[BŁĄD] AMF z N2 po TCP został PRZYJĘTY!"
+else
+  log "[OK] AMF z N2 po TCP został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: UPF z GTP-U bez protokołu (domyślnie TCP) – DENY
+# -------------------------------------------------------------------
+log "==[TC-AMF-2] Krok 4: UPF z 2152 bez protokołu – oczekuję DENY =="
+if pod upf-gtpu-tcp upf '    ports:
+    - name: gtpu
+      containerPort: 2152' >/dev/null 2>&1; then
+  log "[BŁĄD] UPF z GTP-U po TCP został PRZYJĘTY!"
+else
+  log "[OK] UPF z GTP-U po TCP został ODRZUCONY."
+fi
+
+echo
+divider
+log "==[TC-AMF-2] KONIEC TESTU =="
@@ -0,0 +1,95 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="audit-test"
+WH_NS="admission-system"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-AUDIT-1] Audyt w tle: raport admission-audit-report i /metrics =="
+
+echo
+log "==[TC-AUDIT-1] Sprzątanie starego namespace $NS =="
+"${KUBECTL[@]}" delete ns "$NS" --ignore-not-found=true --wait=true >/dev/null 2>&1 || true
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: Pod łamiący reguły tworzony ZANIM namespace ma admission
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-1] Krok 1: namespace bez labela admission + Pod z hostNetwork i tagiem latest =="
+"${KUBECTL[@]}" create ns "$NS" >/dev/null
+cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: audit-bad
+spec:
+  hostNetwork: true
+  containers:
+  - name: app
+    image: docker.io/library/busybox:latest
+    command: ["sh","-c","sleep 3600"]
+YAML
+log "[OK] Pod audit-bad wpuszczony (webhook nie obejmuje jeszcze $NS)."
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: włączenie admission i restart webhooka (skan startuje od razu)
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-1] Krok 2: label admission.kkarczmarek.dev/enabled=true + restart webhooka =="
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$WH_NS" rollout restart deploy/admission-webhook >/dev/null
+"${KUBECTL[@]}" -n "$WH_NS" rollout status deploy/admission-webhook --timeout=120s >/dev/null
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: raport w ConfigMapie admission-audit-report
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-1] Krok 3: oczekuję naruszeń Poda audit-bad w admission-audit-report =="
+REPORT=""
+for _ in $(seq 1 20); do
+  REPORT=$("${KUBECTL[@]}" -n "$NS" get cm admission-audit-report -o jsonpath='{.data.report\.json}' 2>/dev/null || true)
+  [[ -n "$REPORT" ]] && break
+  sleep 3
+done
+if [[ -n "$REPORT" ]] \
+  && [[ "$(echo "$REPORT" | jq '.summary.fail')" -ge 1 ]] \
+  && echo "$REPORT" | jq -e '.results[] | select(.resource.kind=="Pod" and .resource.name=="audit-bad")' >/dev/null; then
+  log "[OK] raport zawiera naruszenia Poda audit-bad:"
+  echo "$REPORT" | jq -r '.results[] | "  \(.resource.kind)/\(.resource.name) \(.field): \(.message)"'
+else
+  log "[BŁĄD] brak raportu albo naruszeń Poda audit-bad!"
+  echo "$REPORT"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: metryki audytu pod /metrics
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-1] Krok 4: admission_audit_objects_failed{namespace=\"$NS\"} w /metrics =="
+"${KUBECTL[@]}" -n "$WH_NS" port-forward deploy/admission-webhook 18443:8443 >/dev/null 2>&1 &
+PF_PID=$!
+sleep 3
+METRICS=$(curl -sk https://127.0.0.1:18443/metrics || true)
+kill "$PF_PID" >/dev/null 2>&1 || true
+if echo "$METRICS" | grep -q "admission_audit_objects_failed{namespace=\"$NS\",kind=\"Pod\"} 1"; then
+  log "[OK] metryka obecna:"
+  echo "$METRICS" | grep "namespace=\"$NS\""
+else
+  log "[BŁĄD] brak metryki dla $NS!"
+  echo "$METRICS" | grep '^admission_audit' || true
+fi
+
+echo
+divider
+log "==[TC-AUDIT-1] Sprzątanie =="
+"${KUBECTL[@]}" delete ns "$NS" --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-AUDIT-1] KONIEC TESTU =="
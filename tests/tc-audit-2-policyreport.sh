@@ -0,0 +1,86 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="audit-pr-test"
+WH_NS="admission-system"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-AUDIT-2] PolicyReport free5gc-admission (wgpolicyk8s.io/v1alpha2) =="
+
+if ! "${KUBECTL[@]}" get crd policyreports.wgpolicyk8s.io >/dev/null 2>&1; then
+  log "[BŁĄD] brak CRD policyreports.wgpolicyk8s.io (zainstaluj Kyverno) – test pominięty."
+  exit 0
+fi
+
+echo
+log "==[TC-AUDIT-2] Sprzątanie starego namespace $NS =="
+"${KUBECTL[@]}" delete ns "$NS" --ignore-not-found=true --wait=true >/dev/null 2>&1 || true
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: Pod z tagiem latest tworzony ZANIM namespace ma admission
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-2] Krok 1: namespace bez labela admission + Pod z tagiem latest =="
+"${KUBECTL[@]}" create ns "$NS" >/dev/null
+cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pr-bad
+spec:
+  containers:
+  - name: app
+    image: docker.io/library/busybox:latest
+    command: ["sh","-c","sleep 3600"]
+YAML
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$WH_NS" rollout restart deploy/admission-webhook >/dev/null
+"${KUBECTL[@]}" -n "$WH_NS" rollout status deploy/admission-webhook --timeout=120s >/dev/null
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: wynik free5gc-containers-image (fail, high) dla Poda pr-bad
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-2] Krok 2: oczekuję reguły free5gc-containers-image w PolicyReport =="
+PR=""
+for _ in $(seq 1 20); do
+  PR=$("${KUBECTL[@]}" -n "$NS" get policyreport free5gc-admission -o json 2>/dev/null || true)
+  [[ -n "$PR" ]] && break
+  sleep 3
+done
+if [[ -n "$PR" ]] && echo "$PR" | jq -e '.results[]
+    | select(.rule=="free5gc-containers-image" and .result=="fail" and .severity=="high"
+             and .resources[0].kind=="Pod" and .resources[0].name=="pr-bad")' >/dev/null; then
+  log "[OK] PolicyReport zawiera naruszenie Poda pr-bad:"
+  echo "$PR" | jq -r '.results[] | "  \(.rule) [\(.severity)] \(.resources[0].kind)/\(.resources[0].name): \(.message)"'
+else
+  log "[BŁĄD] brak oczekiwanego wyniku w PolicyReport!"
+  echo "$PR" | jq '.results' 2>/dev/null || true
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: ClusterPolicyReport free5gc-admission istnieje
+# -------------------------------------------------------------------
+log "==[TC-AUDIT-2] Krok 3: ClusterPolicyReport free5gc-admission =="
+if "${KUBECTL[@]}" get clusterpolicyreport free5gc-admission >/dev/null 2>&1; then
+  log "[OK] ClusterPolicyReport obecny:"
+  "${KUBECTL[@]}" get clusterpolicyreport free5gc-admission -o jsonpath='{.summary}'; echo
+else
+  log "[BŁĄD] brak ClusterPolicyReport free5gc-admission!"
+fi
+
+echo
+divider
+log "==[TC-AUDIT-2] Sprzątanie =="
+"${KUBECTL[@]}" delete ns "$NS" --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-AUDIT-2] KONIEC TESTU =="
@@ -0,0 +1,146 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-CFG-1] Walidacja ConfigMap z konfiguracją NF free5gc (upfcfg/smfcfg) =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+divider
+
+# cm <name> <key> <plik-yaml> – server-side dry-run; stderr -> cm.err
+cm() {
+  "${KUBECTL[@]}" -n "$NS" create configmap "$1" --from-file="$2=$3" \
+    --dry-run=client -o yaml \
+    | "${KUBECTL[@]}" label --local -f - app.kubernetes.io/part-of=free5gc project=free5gc -o yaml \
+    | "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>cm.err
+}
+
+TMP=$(mktemp -d)
+trap 'rm -rf "$TMP" cm.err' EXIT
+
+cat >"$TMP/upf-ok.yaml" <<'YAML'
+version: 1.0.3
+pfcp:
+  addr: 10.100.50.241
+  nodeID: 10.100.50.241
+gtpu:
+  forwarder: gtp5g
+  ifList:
+    - addr: 10.100.50.233
+      type: N3
+dnnList:
+  - dnn: internet
+    cidr: 10.1.0.0/17
+YAML
+
+cat >"$TMP/upf-bad.yaml" <<'YAML'
+version: 1.0.3
+pfcp:
+  addr: upf.local
+  nodeID: 10.100.50.241
+gtpu:
+  ifList:
+    - addr: 10.100.50.233
+      type: N6
+dnnList:
+  - dnn: inter_net
+    cidr: 10.1.0.0/33
+YAML
+
+cat >"$TMP/smf-bad.yaml" <<'YAML'
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: smf-nsmf
+    bindingIPv4: 0.0.0.0
+    port: 8000
+  snssaiInfos:
+    - sNssai:
+        sst: 1
+        sd: 01020
+      dnnInfos:
+        - dnn: internet
+  plmnList:
+    - mcc: 208
+      mnc: 9
+  pfcp:
+    nodeID: 10.100.50.244
+    listenAddr: 10.100.50.244
+  userplaneInformation:
+    upNodes:
+      gNB1:
+        type: AN
+      UPF:
+        type: UPF
+        nodeID: 10.100.50.241
+    links:
+      - A: gNB1
+        B: UPF2
+  nrfUri: nrf-nnrf:8000
+YAML
+
+# -------------------------------------------------------------------
+# KROK 1: poprawny upfcfg.yaml – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-1] Krok 1: poprawny upfcfg.yaml – oczekuję ALLOW =="
+if cm upf-cfg-ok upfcfg.yaml "$TMP/upf-ok.yaml"; then
+  log "[OK] poprawna konfiguracja UPF została PRZYJĘTA."
+else
+  log "[BŁĄD] poprawna konfiguracja UPF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: upfcfg.yaml z błędami – DENY z numerami linii
+# -------------------------------------------------------------------
+log "==[TC-CFG-1] Krok 2: upfcfg.yaml (adres PFCP, typ N6, DNN, CIDR) – oczekuję DENY =="
+if cm upf-cfg-bad upfcfg.yaml "$TMP/upf-bad.yaml"; then
+  log "[BŁĄD] błędna konfiguracja UPF została PRZYJĘTA!"
+else
+  log "[OK] błędna konfiguracja UPF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: smfcfg.yaml z błędami – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-1] Krok 3: smfcfg.yaml (SD, MNC, link, nrfUri) – oczekuję DENY =="
+if cm smf-cfg-bad smfcfg.yaml "$TMP/smf-bad.yaml"; then
+  log "[BŁĄD] błędna konfiguracja SMF została PRZYJĘTA!"
+else
+  log "[OK] błędna konfiguracja SMF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: zepsuty YAML – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-1] Krok 4: niepoprawny składniowo YAML – oczekuję DENY =="
+printf 'configuration:\n  sbi: [\n' >"$TMP/nrf-broken.yaml"
+if cm nrf-cfg-broken nrfcfg.yaml "$TMP/nrf-broken.yaml"; then
+  log "[BŁĄD] zepsuty YAML został PRZYJĘTY!"
+else
+  log "[OK] zepsuty YAML został ODRZUCONY:"
+  cat cm.err
+fi
+
+echo
+divider
+log "==[TC-CFG-1] KONIEC TESTU =="
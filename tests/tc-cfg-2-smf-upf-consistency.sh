@@ -0,0 +1,222 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-CFG-2] Spójność topologii slice'a: smfcfg.yaml (upNodes) <-> UPF =="
+
+echo
+log "==[TC-CFG-2] Sprzątanie starych obiektów (cfg2-*) =="
+"${KUBECTL[@]}" -n "$NS" delete deploy cfg2-upf --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete cm cfg2-smf --ignore-not-found=true >/dev/null 2>&1 || true
+divider
+
+TMP=$(mktemp -d)
+trap 'rm -rf "$TMP" cm.err' EXIT
+
+# upf <dnn> <n4-ip> [--dry-run=server] – Deployment UPF (replicas=0, bez schedulingu)
+upf() {
+  cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply ${3:-} -f - >/dev/null 2>cm.err
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cfg2-upf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+spec:
+  replicas: 0
+  selector:
+    matchLabels:
+      app: cfg2-upf
+  template:
+    metadata:
+      labels:
+        app: cfg2-upf
+        app.kubernetes.io/part-of: free5gc
+        project: free5gc
+        nf: upf
+      annotations:
+        5g.kkarczmarek.dev/networks: "n4-net@$2/24"
+        5g.kkarczmarek.dev/slice-id: "1"
+        5g.kkarczmarek.dev/sst: "1"
+        5g.kkarczmarek.dev/sd: "010203"
+        5g.kkarczmarek.dev/dnn: "$1"
+        5g.kkarczmarek.dev/ue-pool-cidr: "10.60.0.0/16"
+    spec:
+      containers:
+      - name: upf
+        image: docker.io/library/busybox:1.36
+        command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# smf <plik-yaml> [--dry-run=server] – ConfigMap z smfcfg.yaml
+smf() {
+  "${KUBECTL[@]}" -n "$NS" create configmap cfg2-smf --from-file="smfcfg.yaml=$1" \
+    --dry-run=client -o yaml \
+    | "${KUBECTL[@]}" label --local -f - app.kubernetes.io/part-of=free5gc project=free5gc -o yaml \
+    | "${KUBECTL[@]}" apply ${2:-} -f - >/dev/null 2>cm.err
+}
+
+# smf_cfg <sst> <pula> [n4] – minimalny smfcfg.yaml z jednym UPF (domyślnie N4 10.100.50.241)
+smf_cfg() {
+  cat <<YAML
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: smf-nsmf
+    bindingIPv4: 0.0.0.0
+    port: 8000
+  snssaiInfos:
+    - sNssai:
+        sst: $1
+        sd: "010203"
+      dnnInfos:
+        - dnn: internet
+  plmnList:
+    - mcc: "208"
+      mnc: "93"
+  pfcp:
+    nodeID: 10.100.50.244
+    listenAddr: 10.100.50.244
+  userplaneInformation:
+    upNodes:
+      gNB1:
+        type: AN
+      UPF:
+        type: UPF
+        nodeID: ${3:-10.100.50.241}
+        sNssaiUpfInfos:
+          - sNssai:
+              sst: $1
+              sd: "010203"
+            dnnUpfInfoList:
+              - dnn: internet
+                pools:
+                  - cidr: $2
+    links:
+      - A: gNB1
+        B: UPF
+  nrfUri: http://nrf-nnrf:8000
+YAML
+}
+
+smf_cfg 1 10.60.0.0/16 >"$TMP/smf-ok.yaml"
+smf_cfg 2 10.61.0.0/16 >"$TMP/smf-bad.yaml"
+smf_cfg 1 10.60.0.0/16 10.100.50.242 >"$TMP/smf-renumber.yaml"
+
+# -------------------------------------------------------------------
+# KROK 1: UPF (N4 10.100.50.241, DNN internet, S-NSSAI 1/010203) – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 1: Deployment UPF z N4/DNN/S-NSSAI/pulą UE – oczekuję ALLOW =="
+if upf internet 10.100.50.241; then
+  log "[OK] Deployment cfg2-upf został UTWORZONY."
+else
+  log "[BŁĄD] Deployment cfg2-upf został ODRZUCONY:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: smfcfg.yaml zgodny z UPF – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 2: smfcfg.yaml zgodny z UPF – oczekuję ALLOW =="
+if smf "$TMP/smf-ok.yaml"; then
+  log "[OK] zgodna konfiguracja SMF została PRZYJĘTA."
+else
+  log "[BŁĄD] zgodna konfiguracja SMF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: smfcfg.yaml z innym S-NSSAI i pulą spoza ue-pool-cidr – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 3: smfcfg.yaml (sst=2, pula 10.61.0.0/16) – oczekuję DENY =="
+if smf "$TMP/smf-bad.yaml" --dry-run=server; then
+  log "[BŁĄD] niespójna konfiguracja SMF została PRZYJĘTA!"
+else
+  log "[OK] niespójna konfiguracja SMF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: UPF zmienia DNN na ims – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 4: UPF z DNN=ims (SMF oczekuje internet) – oczekuję DENY =="
+if upf ims 10.100.50.241 --dry-run=server; then
+  log "[BŁĄD] zmiana DNN UPF-a została PRZYJĘTA!"
+else
+  log "[OK] zmiana DNN UPF-a została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 5: UPF zmienia adres N4, na który wskazuje SMF – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 5: UPF z N4 10.100.50.242 (SMF wskazuje .241) – oczekuję DENY =="
+if upf internet 10.100.50.242 --dry-run=server; then
+  log "[BŁĄD] zmiana adresu N4 UPF-a została PRZYJĘTA!"
+else
+  log "[OK] zmiana adresu N4 UPF-a została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 6: zmiana N4 w kolejności SMF -> UPF – ALLOW (SMF z ostrzeżeniem)
+# -------------------------------------------------------------------
+log "==[TC-CFG-2] Krok 6: SMF na N4 10.100.50.242, potem UPF – oczekuję ALLOW + Warning =="
+if smf "$TMP/smf-renumber.yaml"; then
+  log "[OK] SMF wskazujący nowy N4 został PRZYJĘTY:"
+  cat cm.err
+  if grep -q "Warning" cm.err; then
+    log "[OK] webhook zwrócił ostrzeżenie o upNode bez UPF-a."
+  else
+    log "[BŁĄD] brak ostrzeżenia o upNode bez UPF-a!"
+  fi
+  # ostrzeżenie trafia też do eventów (Warning AdmissionWarning na ConfigMapie)
+  EVENT=""
+  for _ in $(seq 1 10); do
+    EVENT=$("${KUBECTL[@]}" -n "$NS" get events \
+      --field-selector "involvedObject.kind=ConfigMap,involvedObject.name=cfg2-smf,reason=AdmissionWarning" \
+      -o jsonpath='{.items[-1:].message}' 2>/dev/null || true)
+    [[ -n "$EVENT" ]] && break
+    sleep 2
+  done
+  if [[ -n "$EVENT" ]]; then
+    log "[OK] event AdmissionWarning na cm/cfg2-smf: ${EVENT}"
+  else
+    log "[BŁĄD] brak eventu AdmissionWarning na cm/cfg2-smf!"
+  fi
+else
+  log "[BŁĄD] SMF wskazujący nowy N4 został ODRZUCONY:"
+  cat cm.err
+fi
+if upf internet 10.100.50.242; then
+  log "[OK] UPF z nowym N4 został PRZYJĘTY – zmiana numeracji zakończona."
+else
+  log "[BŁĄD] UPF z nowym N4 został ODRZUCONY:"
+  cat cm.err
+fi
+
+echo
+divider
+log "==[TC-CFG-2] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete deploy cfg2-upf --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete cm cfg2-smf --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-CFG-2] KONIEC TESTU =="
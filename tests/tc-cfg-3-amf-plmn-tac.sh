@@ -0,0 +1,184 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-CFG-3] PLMN / TAC / GUAMI AMF-a (amfcfg.yaml + anotacje plmn/tac) =="
+
+echo
+log "==[TC-CFG-3] Sprzątanie starych obiektów (cfg3-*) =="
+"${KUBECTL[@]}" -n "$NS" delete deploy cfg3-amf --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete cm cfg3-amf-cfg --ignore-not-found=true >/dev/null 2>&1 || true
+divider
+
+TMP=$(mktemp -d)
+trap 'rm -rf "$TMP" cm.err' EXIT
+
+# amf <plmn> <tac> [--dry-run=server] – Deployment AMF (replicas=0)
+amf() {
+  cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply ${3:-} -f - >/dev/null 2>cm.err
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cfg3-amf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: amf
+spec:
+  replicas: 0
+  selector:
+    matchLabels:
+      app: cfg3-amf
+  template:
+    metadata:
+      labels:
+        app: cfg3-amf
+        app.kubernetes.io/part-of: free5gc
+        project: free5gc
+        nf: amf
+      annotations:
+        5g.kkarczmarek.dev/plmn: "$1"
+        5g.kkarczmarek.dev/tac: "$2"
+    spec:
+      containers:
+      - name: amf
+        image: docker.io/library/busybox:1.36
+        command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# cm <name> <plik-yaml> [--dry-run=none] – ConfigMapa z amfcfg.yaml (domyślnie server-side dry-run)
+cm() {
+  "${KUBECTL[@]}" -n "$NS" create configmap "$1" --from-file="amfcfg.yaml=$2" \
+    --dry-run=client -o yaml \
+    | "${KUBECTL[@]}" label --local -f - app.kubernetes.io/part-of=free5gc project=free5gc -o yaml \
+    | "${KUBECTL[@]}" apply "${3:---dry-run=server}" -f - >/dev/null 2>cm.err
+}
+
+# amf_cfg <mnc> <amfId> <tac> – minimalny amfcfg.yaml
+amf_cfg() {
+  cat <<YAML
+configuration:
+  ngapIpList:
+    - 10.100.50.249
+  sbi:
+    scheme: http
+    registerIPv4: amf-namf
+    bindingIPv4: 0.0.0.0
+    port: 8000
+  nrfUri: http://nrf-nnrf:8000
+  servedGuamiList:
+    - plmnId:
+        mcc: "208"
+        mnc: "$1"
+      amfId: $2
+  supportTaiList:
+    - plmnId:
+        mcc: "208"
+        mnc: "$1"
+      tac: $3
+  plmnSupportList:
+    - plmnId:
+        mcc: "208"
+        mnc: "$1"
+      snssaiList:
+        - sst: 1
+          sd: "010203"
+  supportDnnList:
+    - internet
+YAML
+}
+
+amf_cfg 93 cafe00 '"000001"' >"$TMP/amf-ok.yaml"
+amf_cfg 93 cafe0 '"1"' >"$TMP/amf-bad.yaml"
+amf_cfg 95 cafe00 '"000001"' >"$TMP/amf-other-plmn.yaml"
+
+# -------------------------------------------------------------------
+# KROK 1: AMF z plmn=208-93, tac=000001 – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 1: Deployment AMF (plmn=208-93, tac=000001) – oczekuję ALLOW =="
+if amf 208-93 000001; then
+  log "[OK] Deployment cfg3-amf został UTWORZONY."
+else
+  log "[BŁĄD] Deployment cfg3-amf został ODRZUCONY:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: anotacje z błędnym MCC i TAC – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 2: plmn=20-93, tac=1 – oczekuję DENY =="
+if amf 20-93 1 --dry-run=server; then
+  log "[BŁĄD] błędne anotacje plmn/tac zostały PRZYJĘTE!"
+else
+  log "[OK] błędne anotacje plmn/tac zostały ODRZUCONE:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: amfcfg.yaml zgodny z AMF w namespace – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 3: amfcfg.yaml (208-93, amfId cafe00, tac 000001) – oczekuję ALLOW =="
+if cm cfg3-amf-ok "$TMP/amf-ok.yaml"; then
+  log "[OK] poprawna konfiguracja AMF została PRZYJĘTA."
+else
+  log "[BŁĄD] poprawna konfiguracja AMF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: amfcfg.yaml z błędnym amfId i TAC – DENY z numerami linii
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 4: amfcfg.yaml (amfId cafe0, tac 1) – oczekuję DENY =="
+if cm cfg3-amf-bad "$TMP/amf-bad.yaml"; then
+  log "[BŁĄD] błędna konfiguracja AMF została PRZYJĘTA!"
+else
+  log "[OK] błędna konfiguracja AMF została ODRZUCONA:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 5: inny zbiór PLMN niż pozostałe AMF-y – DENY
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 5: amfcfg.yaml z PLMN 208-95 (AMF w namespace: 208-93) – oczekuję DENY =="
+if cm cfg3-amf-other "$TMP/amf-other-plmn.yaml"; then
+  log "[BŁĄD] niespójny zbiór PLMN został PRZYJĘTY!"
+else
+  log "[OK] niespójny zbiór PLMN został ODRZUCONY:"
+  cat cm.err
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 6: dodanie PLMN – AMF z nadzbiorem, potem ConfigMapa – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-CFG-3] Krok 6: ConfigMapa (208-93) + AMF z plmn=208-93,208-95 (nadzbiór) – oczekuję ALLOW =="
+cm cfg3-amf-cfg "$TMP/amf-ok.yaml" --dry-run=none || true
+if amf 208-93,208-95 000001; then
+  log "[OK] AMF z nadzbiorem PLMN został PRZYJĘTY (krok migracji)."
+else
+  log "[BŁĄD] AMF z nadzbiorem PLMN został ODRZUCONY:"
+  cat cm.err
+fi
+
+echo
+divider
+log "==[TC-CFG-3] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete deploy cfg3-amf --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete cm cfg3-amf-cfg --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-CFG-3] KONIEC TESTU =="
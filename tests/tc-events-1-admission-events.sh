@@ -0,0 +1,145 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+DEPLOY="events-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+# wait_event <kind> <name> <reason> – czeka na Event o podanym powodzie
+wait_event() {
+  for _ in $(seq 1 20); do
+    if "${KUBECTL[@]}" -n "$NS" get events \
+        --field-selector "involvedObject.kind=$1,involvedObject.name=$2,reason=$3" \
+        -o name 2>/dev/null | grep -q .; then
+      return 0
+    fi
+    sleep 2
+  done
+  return 1
+}
+
+log "==[TC-EVENTS-1] Eventy webhooka: SidecarInjected i AdmissionDenied =="
+
+echo
+log "==[TC-EVENTS-1] Sprzątanie starych obiektów =="
+"${KUBECTL[@]}" -n "$NS" delete deploy "$DEPLOY" "${DEPLOY}-new" --ignore-not-found=true --wait=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete pod events-latest --ignore-not-found=true >/dev/null 2>&1 || true
+divider
+
+# upf_deploy <tcpdump-enabled> [nazwa] – Deployment UPF (apply)
+upf_deploy() {
+  local name=${2:-$DEPLOY}
+  cat <<YAML | "${KUBECTL[@]}" apply -f - >/dev/null
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${name}
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: ${name}
+  template:
+    metadata:
+      labels:
+        app: ${name}
+        app.kubernetes.io/part-of: free5gc
+        project: free5gc
+        nf: upf
+      annotations:
+        5g.kkarczmarek.dev/tcpdump-enabled: "$1"
+        5g.kkarczmarek.dev/networks: "n6-net@10.100.10.5/24"
+    spec:
+      containers:
+      - name: main
+        image: docker.io/library/busybox:1.36
+        command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: nowy Deployment UPF z tcpdump-enabled=true -> Normal SidecarInjected
+# na namespace (przy CREATE obiektu jeszcze nie ma)
+# -------------------------------------------------------------------
+log "==[TC-EVENTS-1] Krok 1: CREATE Deploymentu UPF z tcpdump-enabled=true – oczekuję SidecarInjected na namespace =="
+upf_deploy true "${DEPLOY}-new"
+if wait_event Namespace "$NS" SidecarInjected; then
+  log "[OK] event SidecarInjected na namespace ${NS}:"
+  "${KUBECTL[@]}" -n "$NS" get events --field-selector "involvedObject.kind=Namespace,reason=SidecarInjected" \
+    -o custom-columns=TYPE:.type,REASON:.reason,MESSAGE:.message | grep -e MESSAGE -e "${DEPLOY}-new" || true
+else
+  log "[BŁĄD] brak eventu SidecarInjected na namespace ${NS}!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: istniejący Deployment UPF, UPDATE tcpdump-enabled=true
+# -> Normal SidecarInjected na samym Deploymencie
+# -------------------------------------------------------------------
+log "==[TC-EVENTS-1] Krok 2: Deployment UPF + UPDATE tcpdump-enabled=true – oczekuję eventu SidecarInjected =="
+upf_deploy false
+upf_deploy true
+if wait_event Deployment "$DEPLOY" SidecarInjected; then
+  log "[OK] event SidecarInjected na Deployment ${DEPLOY}:"
+  "${KUBECTL[@]}" -n "$NS" get events --field-selector "involvedObject.name=${DEPLOY},reason=SidecarInjected" \
+    -o custom-columns=TYPE:.type,REASON:.reason,MESSAGE:.message
+else
+  log "[BŁĄD] brak eventu SidecarInjected dla ${DEPLOY}!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: Pod z tagiem latest -> odmowa + Warning AdmissionDenied na namespace
+# -------------------------------------------------------------------
+log "==[TC-EVENTS-1] Krok 3: Pod z tagiem latest – oczekuję odmowy i eventu AdmissionDenied na namespace =="
+set +e
+OUT=$(cat <<YAML | "${KUBECTL[@]}" apply -f - 2>&1
+apiVersion: v1
+kind: Pod
+metadata:
+  name: events-latest
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  containers:
+  - name: app
+    image: docker.io/library/busybox:latest
+    command: ["sh","-c","sleep 3600"]
+YAML
+)
+RC=$?
+set -e
+if [[ $RC -ne 0 ]]; then
+  log "[OK] Pod odrzucony: ${OUT}"
+else
+  log "[BŁĄD] Pod z tagiem latest został wpuszczony!"
+fi
+if wait_event Namespace "$NS" AdmissionDenied; then
+  log "[OK] event AdmissionDenied na namespace ${NS}:"
+  "${KUBECTL[@]}" -n "$NS" get events --field-selector "involvedObject.kind=Namespace,reason=AdmissionDenied" \
+    -o custom-columns=TYPE:.type,COUNT:.count,MESSAGE:.message | tail -n 3
+else
+  log "[BŁĄD] brak eventu AdmissionDenied na namespace ${NS}!"
+fi
+
+echo
+divider
+log "==[TC-EVENTS-1] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete deploy "$DEPLOY" "${DEPLOY}-new" --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete pod events-latest --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-EVENTS-1] KONIEC TESTU =="
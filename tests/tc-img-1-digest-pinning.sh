@@ -0,0 +1,88 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+# lokalny rejestr microk8s (addon registry) – webhook musi mieć go w INSECURE_REGISTRIES
+REGISTRY="${REGISTRY:-localhost:32000}"
+IMAGE="${REGISTRY}/busybox:1.36"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-IMG-1] Przypinanie obrazów digestem (REQUIRE/RESOLVE_IMAGE_DIGEST) =="
+log "  -> zakładam, że webhook działa z REQUIRE_IMAGE_DIGEST=true i RESOLVE_IMAGE_DIGEST=true"
+
+# -------------------------------------------------------------------
+# KROK 0: obraz testowy w lokalnym rejestrze
+# -------------------------------------------------------------------
+log "==[TC-IMG-1] Krok 0: push ${IMAGE} do lokalnego rejestru =="
+docker pull docker.io/library/busybox:1.36 >/dev/null
+docker tag docker.io/library/busybox:1.36 "$IMAGE"
+docker push "$IMAGE" >/dev/null
+EXPECTED=$(curl -sI -H 'Accept: application/vnd.docker.distribution.manifest.v2+json' \
+  "http://${REGISTRY}/v2/busybox/manifests/1.36" | awk -F': ' 'tolower($1)=="docker-content-digest"{print $2}' | tr -d '\r')
+log "  -> digest w rejestrze: ${EXPECTED}"
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: Pod z tagiem – mutator powinien dopisać @sha256
+# -------------------------------------------------------------------
+log "==[TC-IMG-1] Krok 1: Pod z ${IMAGE} – oczekuję ALLOW i obrazu @${EXPECTED} =="
+
+GOT=$(cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply --dry-run=server -o jsonpath='{.spec.containers[0].image}' -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: img-digest-resolve
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  containers:
+  - name: main
+    image: ${IMAGE}
+    command: ["sh","-c","sleep 3600"]
+YAML
+)
+
+if [ "$GOT" = "${IMAGE}@${EXPECTED}" ]; then
+  log "[OK] obraz po mutacji: ${GOT}"
+else
+  log "[BŁĄD] obraz po mutacji: ${GOT} (oczekiwano ${IMAGE}@${EXPECTED})"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: tag, którego nie ma w rejestrze – brak digesta => DENY
+# -------------------------------------------------------------------
+log "==[TC-IMG-1] Krok 2: Pod z nieistniejącym tagiem – oczekuję DENY (brak @sha256) =="
+
+if cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply --dry-run=server -f - >/dev/null 2>&1; then
+apiVersion: v1
+kind: Pod
+metadata:
+  name: img-digest-missing
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  containers:
+  - name: main
+    image: ${REGISTRY}/busybox:does-not-exist
+    command: ["sh","-c","sleep 3600"]
+YAML
+  log "[BŁĄD] img-digest-missing został PRZYJĘTY, a obraz nie ma digesta!"
+else
+  log "[OK] img-digest-missing został ODRZUCONY – obraz bez @sha256."
+fi
+
+echo
+divider
+log "==[TC-IMG-1] KONIEC TESTU =="
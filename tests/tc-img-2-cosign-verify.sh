@@ -0,0 +1,97 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+# lokalny rejestr microk8s (addon registry) – webhook musi mieć go w INSECURE_REGISTRIES
+REGISTRY="${REGISTRY:-localhost:32000}"
+WORK="$(mktemp -d)"
+trap 'rm -rf "$WORK"' EXIT
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-IMG-2] Weryfikacja podpisów cosign w webhooku (COSIGN_VERIFY) =="
+
+# -------------------------------------------------------------------
+# KROK 0: klucz, dwa obrazy (podpisany i niepodpisany), polityka w ConfigMapie
+# -------------------------------------------------------------------
+log "==[TC-IMG-2] Krok 0: przygotowanie obrazów i klucza =="
+
+(cd "$WORK" && COSIGN_PASSWORD="" cosign generate-key-pair >/dev/null)
+
+docker pull docker.io/library/busybox:1.36 >/dev/null
+for name in signed unsigned; do
+  docker tag docker.io/library/busybox:1.36 "${REGISTRY}/cosign-${name}:1.36"
+  docker push "${REGISTRY}/cosign-${name}:1.36" >/dev/null
+done
+
+COSIGN_PASSWORD="" cosign sign --yes --tlog-upload=false --allow-insecure-registry \
+  --key "$WORK/cosign.key" "${REGISTRY}/cosign-signed:1.36" >/dev/null
+
+{
+  echo "registries:"
+  echo "  - prefix: ${REGISTRY}/cosign-"
+  echo "    keys:"
+  echo "      - |"
+  sed 's/^/        /' "$WORK/cosign.pub"
+} >"$WORK/policy.yaml"
+
+"${KUBECTL[@]}" -n admission-system create configmap admission-webhook-cosign \
+  --from-file=policy.yaml="$WORK/policy.yaml" --dry-run=client -o yaml | "${KUBECTL[@]}" apply -f -
+# COSIGN_VERIFY jest domyślnie wyłączone w k8s/30-webhook-deploy-svc.yaml;
+# zmiana env restartuje webhook, więc wczyta też nową politykę
+"${KUBECTL[@]}" -n admission-system set env deploy/admission-webhook COSIGN_VERIFY=true >/dev/null
+"${KUBECTL[@]}" -n admission-system rollout restart deploy/admission-webhook >/dev/null
+"${KUBECTL[@]}" -n admission-system rollout status deploy/admission-webhook --timeout=120s >/dev/null
+log "  -> polityka cosign wgrana, COSIGN_VERIFY=true, webhook zrestartowany"
+divider
+
+# apply_pod <name> <image> – zwraca 0 gdy Pod przyjęty (server-side dry-run)
+apply_pod() {
+  cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply --dry-run=server -f - >/dev/null 2>&1
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  containers:
+  - name: main
+    image: $2
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: podpisany obraz – ALLOW
+# -------------------------------------------------------------------
+log "==[TC-IMG-2] Krok 1: podpisany obraz – oczekuję ALLOW =="
+if apply_pod cosign-signed "${REGISTRY}/cosign-signed:1.36"; then
+  log "[OK] podpisany obraz został PRZYJĘTY."
+else
+  log "[BŁĄD] podpisany obraz został ODRZUCONY!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: niepodpisany obraz pod tym samym prefiksem – DENY
+# -------------------------------------------------------------------
+log "==[TC-IMG-2] Krok 2: niepodpisany obraz – oczekuję DENY =="
+if apply_pod cosign-unsigned "${REGISTRY}/cosign-unsigned:1.36"; then
+  log "[BŁĄD] niepodpisany obraz został PRZYJĘTY!"
+else
+  log "[OK] niepodpisany obraz został ODRZUCONY przez webhook."
+fi
+
+echo
+divider
+log "==[TC-IMG-2] KONIEC TESTU =="
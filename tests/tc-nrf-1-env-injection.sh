@@ -0,0 +1,123 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-NRF-1] Wstrzykiwanie NRF_URI / POD_IP / slice do kontenera NF =="
+
+echo
+log "==[TC-NRF-1] Sprzątanie starych obiektów (nrf1-*) =="
+"${KUBECTL[@]}" -n "$NS" delete pod nrf1-smf nrf1-smf-own --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete svc nrf1-nnrf --ignore-not-found=true >/dev/null 2>&1 || true
+divider
+
+# env <pod> <nazwa> – wartość (albo fieldPath) zmiennej w kontenerze smf
+env_of() {
+  "${KUBECTL[@]}" -n "$NS" get pod "$1" -o json \
+    | jq -r --arg n "$2" '.spec.containers[] | select(.name=="smf") | .env[]? | select(.name==$n) | (.value // .valueFrom.fieldRef.fieldPath)'
+}
+
+# -------------------------------------------------------------------
+# KROK 1: Service NRF (selektor nf=nrf, port sbi)
+# -------------------------------------------------------------------
+log "==[TC-NRF-1] Krok 1: tworzę Service nrf1-nnrf (nf=nrf, sbi 8000) =="
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Service
+metadata:
+  name: nrf1-nnrf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: nrf
+spec:
+  selector:
+    nf: nrf
+  ports:
+  - name: sbi
+    port: 8000
+    targetPort: 8000
+YAML
+log "[OK] Service nrf1-nnrf utworzony."
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: Pod SMF – oczekuję NRF_URI, POD_IP i SMF_* z anotacji
+# -------------------------------------------------------------------
+log "==[TC-NRF-1] Krok 2: Pod nf=smf – oczekuję NRF_URI, POD_IP, SMF_SST/SMF_DNN =="
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nrf1-smf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: smf
+  annotations:
+    5g.kkarczmarek.dev/sst: "1"
+    5g.kkarczmarek.dev/dnn: "internet"
+spec:
+  containers:
+  - name: smf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+
+NRF_URI=$(env_of nrf1-smf NRF_URI)
+# <svc>.<ns>.svc[.<CLUSTER_DOMAIN>]:8000
+if [[ "$NRF_URI" =~ nrf[^.]*\.$NS\.svc(\.[a-z0-9.-]+)?:8000$ ]] \
+  && [[ "$(env_of nrf1-smf POD_IP)" == "status.podIP" ]] \
+  && [[ "$(env_of nrf1-smf SMF_DNN)" == "internet" ]]; then
+  log "[OK] zmienne wstrzyknięte (NRF_URI=$NRF_URI)."
+else
+  log "[BŁĄD] brak oczekiwanych zmiennych w kontenerze smf:"
+  "${KUBECTL[@]}" -n "$NS" get pod nrf1-smf -o jsonpath='{.spec.containers[0].env}'; echo
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: zmienna ustawiona przez użytkownika nie jest nadpisywana
+# -------------------------------------------------------------------
+log "==[TC-NRF-1] Krok 3: Pod z własnym NRF_URI – oczekuję wartości użytkownika =="
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nrf1-smf-own
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: smf
+spec:
+  containers:
+  - name: smf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+    env:
+    - name: NRF_URI
+      value: http://my-nrf:8000
+YAML
+
+if [[ "$(env_of nrf1-smf-own NRF_URI)" == "http://my-nrf:8000" ]]; then
+  log "[OK] NRF_URI użytkownika zachowany."
+else
+  log "[BŁĄD] NRF_URI użytkownika został nadpisany!"
+fi
+
+echo
+divider
+log "==[TC-NRF-1] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete pod nrf1-smf nrf1-smf-own --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete svc nrf1-nnrf --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-NRF-1] KONIEC TESTU =="
@@ -0,0 +1,193 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+# Każdy przypadek jest aplikowany (server-side dry-run) jako Pod oraz jako
+# szablon w każdym workloadzie. Webhook musi dać ten sam werdykt dla
+# wszystkich rodzajów – inaczej reguły znowu się rozjechały.
+KINDS=(Pod Deployment StatefulSet DaemonSet ReplicaSet Job CronJob)
+
+FAILED=0
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+# render_kind <kind> <name> <metadata-labels> <metadata-annotations> <pod-spec>
+# Wszystkie fragmenty YAML są wcięte dla poziomu "spec" Poda (0 spacji),
+# funkcja sama przesuwa je na właściwe miejsce w szablonie.
+indent() {
+  local n="$1"
+  sed "s/^/$(printf '%*s' "$n" '')/"
+}
+
+render_kind() {
+  local kind="$1" name="$2" labels="$3" annotations="$4" spec="$5"
+  local lname
+  lname="$(echo "$kind" | tr '[:upper:]' '[:lower:]')"
+
+  case "$kind" in
+    Pod)
+      cat <<YAML
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ${name}-${lname}
+  labels:
+$(echo "$labels" | indent 4)
+  annotations:
+$(echo "$annotations" | indent 4)
+spec:
+$(echo "$spec" | indent 2)
+YAML
+      ;;
+    Deployment|StatefulSet|DaemonSet|ReplicaSet)
+      local api="apps/v1" extra=""
+      if [ "$kind" = "StatefulSet" ]; then
+        extra="  serviceName: ${name}-${lname}"
+      fi
+      cat <<YAML
+apiVersion: ${api}
+kind: ${kind}
+metadata:
+  name: ${name}-${lname}
+  labels:
+$(echo "$labels" | indent 4)
+spec:
+${extra}
+  selector:
+    matchLabels:
+      tc: ${name}
+  template:
+    metadata:
+      labels:
+        tc: ${name}
+$(echo "$labels" | indent 8)
+      annotations:
+$(echo "$annotations" | indent 8)
+    spec:
+$(echo "$spec" | indent 6)
+YAML
+      ;;
+    Job)
+      cat <<YAML
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: ${name}-${lname}
+  labels:
+$(echo "$labels" | indent 4)
+spec:
+  template:
+    metadata:
+      labels:
+$(echo "$labels" | indent 8)
+      annotations:
+$(echo "$annotations" | indent 8)
+    spec:
+      restartPolicy: Never
+$(echo "$spec" | indent 6)
+YAML
+      ;;
+    CronJob)
+      cat <<YAML
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: ${name}-${lname}
+  labels:
+$(echo "$labels" | indent 4)
+spec:
+  schedule: "*/5 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+$(echo "$labels" | indent 12)
+          annotations:
+$(echo "$annotations" | indent 12)
+        spec:
+          restartPolicy: Never
+$(echo "$spec" | indent 10)
+YAML
+      ;;
+  esac
+}
+
+# run_case <name> <expected ALLOW|DENY> <labels> <annotations> <spec>
+run_case() {
+  local name="$1" expected="$2" labels="$3" annotations="$4" spec="$5"
+
+  divider
+  log "==[TC-PARITY-1] Przypadek ${name} – oczekuję ${expected} dla: ${KINDS[*]} =="
+
+  for kind in "${KINDS[@]}"; do
+    local got
+    if render_kind "$kind" "$name" "$labels" "$annotations" "$spec" \
+        | "${KUBECTL[@]}" -n "$NS" apply --dry-run=server -f - >/dev/null 2>&1; then
+      got="ALLOW"
+    else
+      got="DENY"
+    fi
+
+    if [ "$got" = "$expected" ]; then
+      log "  [OK]   ${kind}: ${got}"
+    else
+      log "  [BŁĄD] ${kind}: ${got} (oczekiwano ${expected})"
+      FAILED=1
+    fi
+  done
+}
+
+COMMON_LABELS='app.kubernetes.io/part-of: free5gc
+project: free5gc'
+
+UPF_LABELS="${COMMON_LABELS}
+nf: upf"
+
+CONTAINER='containers:
+- name: main
+  image: docker.io/library/busybox:1.36
+  command: ["sh","-c","sleep 3600"]
+  resources:
+    requests: {cpu: 50m, memory: 64Mi}
+    limits: {cpu: 100m, memory: 128Mi}'
+
+log "==[TC-PARITY-1] Parytet reguł: Pod vs workloady (namespace ${NS}) =="
+
+# 1) poprawny obiekt – wszędzie ALLOW
+run_case "parity-ok" ALLOW "$COMMON_LABELS" 'example.com/none: "true"' "$CONTAINER"
+
+# 2) hostPath bez allow-hostpath – wszędzie DENY
+run_case "parity-hostpath" DENY "$COMMON_LABELS" 'example.com/none: "true"' "${CONTAINER}
+volumes:
+- name: host
+  hostPath:
+    path: /var/run"
+
+# 3) required-ports bez wystawionego portu – wszędzie DENY
+run_case "parity-ports" DENY "$COMMON_LABELS" '5g.kkarczmarek.dev/required-ports: "8805"' "$CONTAINER"
+
+# 4) UPF z adresem spoza DATA_CIDR w 5g.kkarczmarek.dev/networks – wszędzie DENY
+#    (wcześniej sprawdzane tylko dla gołych Podów)
+run_case "parity-upf-networks" DENY "$UPF_LABELS" '5g.kkarczmarek.dev/networks: "n6-net@192.168.10.5/24"' "$CONTAINER"
+
+# 5) UPF z poprawnym adresem – wszędzie ALLOW
+run_case "parity-upf-networks-ok" ALLOW "$UPF_LABELS" '5g.kkarczmarek.dev/networks: "n6-net@10.100.10.5/24"' "$CONTAINER"
+
+echo
+divider
+if [ "$FAILED" -eq 0 ]; then
+  log "==[TC-PARITY-1] KONIEC TESTU – wszystkie rodzaje obiektów dały ten sam werdykt =="
+else
+  log "==[TC-PARITY-1] KONIEC TESTU – znaleziono rozbieżności (szczegóły powyżej) =="
+  exit 1
+fi
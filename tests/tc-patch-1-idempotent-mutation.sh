@@ -0,0 +1,66 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+OUT="$(mktemp)"
+trap 'rm -f "$OUT"' EXIT
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-PATCH-1] Idempotentny patch webhooka (reinvocationPolicy: IfNeeded) =="
+
+# -------------------------------------------------------------------
+# KROK 1: UPF z tcpdump-enabled, częściowo uzupełnionymi portami i pustymi labelami
+# -------------------------------------------------------------------
+log "==[TC-PATCH-1] Krok 1: dry-run UPF – zapisuję obiekt po mutacji =="
+
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply --dry-run=server -o json -f - >"$OUT"
+apiVersion: v1
+kind: Pod
+metadata:
+  name: upf-patch-idem
+  labels: {}
+  annotations:
+    5g.kkarczmarek.dev/tcpdump-enabled: "true"
+spec:
+  containers:
+  - name: upf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+    ports:
+    - name: pfcp
+      containerPort: 8805
+      protocol: UDP
+YAML
+
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: ponowny dry-run na już zmutowanym obiekcie – nic nie może się zdublować
+# -------------------------------------------------------------------
+log "==[TC-PATCH-1] Krok 2: drugi dry-run na obiekcie po mutacji =="
+
+"${KUBECTL[@]}" -n "$NS" apply --dry-run=server -o json -f "$OUT" >"${OUT}.2"
+
+SIDECARS=$(grep -c '"name": "tcpdump-sidecar"' "${OUT}.2" || true)
+GTPU=$(grep -c '"containerPort": 2152' "${OUT}.2" || true)
+rm -f "${OUT}.2"
+
+if [ "$SIDECARS" -eq 1 ] && [ "$GTPU" -eq 1 ]; then
+  log "[OK] jeden sidecar tcpdump i jeden port GTP-U – patch jest idempotentny."
+else
+  log "[BŁĄD] sidecarów: ${SIDECARS}, portów 2152: ${GTPU} – oczekiwano po 1!"
+  exit 1
+fi
+
+echo
+divider
+log "==[TC-PATCH-1] KONIEC TESTU =="
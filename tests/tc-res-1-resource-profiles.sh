@@ -0,0 +1,151 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-RES-1] Profile zasobów NF (k8s/36-resource-profiles.yaml): wybór profilu, sidecary, init containery =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+divider
+
+# pod <name> <nf> <anotacje-yaml> <dodatki-spec-yaml> – server-side dry-run,
+# główny kontener nazywa się jak NF i nie ma zasobów
+pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: $2
+  annotations:
+    test: tc-res-1
+$3
+spec:
+  containers:
+  - name: $2
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+$4
+YAML
+}
+
+# res <json> <kontener|init:kontener> – "req.cpu/req.mem lim.cpu/lim.mem"
+res() {
+  local list=containers name=$2
+  if [[ "$name" == init:* ]]; then
+    list=initContainers
+    name=${name#init:}
+  fi
+  echo "$1" | jq -r --arg l "$list" --arg n "$name" \
+    '.spec[$l][] | select(.name==$n) | .resources
+     | "\(.requests.cpu)/\(.requests.memory) \(.limits.cpu)/\(.limits.memory)"'
+}
+
+# expect <opis> <otrzymane> <oczekiwane>
+expect() {
+  log "  -> $1: $2"
+  if [[ "$2" == "$3" ]]; then
+    log "[OK] $1 zgodne z profilem ($3)."
+  else
+    log "[BŁĄD] $1: oczekiwano $3!"
+  fi
+}
+
+# -------------------------------------------------------------------
+# KROK 1: profil z labela nf (amf) – requests i limity, także CPU
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 1: Pod nf=amf bez zasobów – oczekuję profilu amf =="
+OUT=$(pod res1-amf amf "" "")
+expect "amf" "$(res "$OUT" amf)" "50m/128Mi 300m/256Mi"
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: anotacja resource-profile wygrywa z labelem nf
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 2: Pod nf=amf z resource-profile=upf – oczekuję profilu upf =="
+OUT=$(pod res1-amf-upf amf "    5g.kkarczmarek.dev/resource-profile: upf" "")
+expect "amf (profil upf)" "$(res "$OUT" amf)" "80m/128Mi 400m/512Mi"
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: NF bez profilu – profil default
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 3: Pod nf=nssf (brak profilu) – oczekuję profilu default =="
+OUT=$(pod res1-nssf nssf "" "")
+expect "nssf" "$(res "$OUT" nssf)" "50m/128Mi 500m/512Mi"
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: sidecar – znany (tcpdump-sidecar) i pozostałe (sidecarDefaults)
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 4: Pod nf=smf z kontenerami helper i tcpdump-sidecar – oczekuję profili sidecarów =="
+SIDECARS='  - name: helper
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+  - name: tcpdump-sidecar
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]'
+OUT=$(pod res1-smf smf "" "$SIDECARS")
+expect "smf" "$(res "$OUT" smf)" "50m/128Mi 300m/256Mi"
+expect "helper (sidecarDefaults)" "$(res "$OUT" helper)" "10m/32Mi 100m/64Mi"
+expect "tcpdump-sidecar" "$(res "$OUT" tcpdump-sidecar)" "20m/32Mi 200m/128Mi"
+divider
+
+# -------------------------------------------------------------------
+# KROK 5: init container – profil initContainers
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 5: Pod nf=smf z init containerem wait-for-nrf – oczekuję profilu initContainers =="
+INIT='  initContainers:
+  - name: wait-for-nrf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","true"]'
+OUT=$(pod res1-smf-init smf "" "$INIT")
+expect "wait-for-nrf" "$(res "$OUT" init:wait-for-nrf)" "10m/16Mi 100m/64Mi"
+divider
+
+# -------------------------------------------------------------------
+# KROK 6: jawne wartości zostają, uzupełniane są tylko brakujące klucze
+# -------------------------------------------------------------------
+log "==[TC-RES-1] Krok 6: Pod nf=smf z limits.cpu=250m – oczekuję 250m + reszty z profilu smf =="
+OUT=$(cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: res1-smf-cpu
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: smf
+spec:
+  containers:
+  - name: smf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+    resources:
+      limits:
+        cpu: 250m
+YAML
+)
+expect "smf" "$(res "$OUT" smf)" "50m/128Mi 250m/256Mi"
+
+echo
+divider
+log "==[TC-RES-1] KONIEC TESTU =="
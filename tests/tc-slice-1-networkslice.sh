@@ -0,0 +1,133 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-SLICE-1] NetworkSlice: anotacje z slice'a, walidacja referencji, status =="
+
+echo
+log "==[TC-SLICE-1] Sprzątanie starych obiektów (slice1-*) =="
+"${KUBECTL[@]}" -n "$NS" delete pod slice1-upf slice1-bad --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete networkslice slice1-embb --ignore-not-found=true >/dev/null 2>&1 || true
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: NetworkSlice slice1-embb
+# -------------------------------------------------------------------
+log "==[TC-SLICE-1] Krok 1: tworzę NetworkSlice slice1-embb (sst=1, sd=010203, DNN internet) =="
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: slicing.kkarczmarek.dev/v1alpha1
+kind: NetworkSlice
+metadata:
+  name: slice1-embb
+spec:
+  sliceId: "1"
+  snssai:
+    sst: 1
+    sd: "010203"
+  dnns:
+  - name: internet
+    uePoolCidr: 10.60.0.0/16
+  dataPlane:
+    n6Cidr: 10.100.100.0/24
+    networks: ["n3-net", "n4-net", "n6-net"]
+YAML
+log "[OK] NetworkSlice slice1-embb utworzony."
+sleep 2   # cache informera w webhooku
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: UPF wskazujący slice – anotacje uzupełnione z NetworkSlice
+# -------------------------------------------------------------------
+log "==[TC-SLICE-1] Krok 2: Pod UPF z network-slice=slice1-embb – oczekuję anotacji ze slice'a =="
+cat <<'YAML' | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: slice1-upf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+  annotations:
+    5g.kkarczmarek.dev/network-slice: slice1-embb
+    5g.kkarczmarek.dev/networks: "n4-net@10.100.50.241/24"
+spec:
+  containers:
+  - name: upf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+
+OUT=$("${KUBECTL[@]}" -n "$NS" get pod slice1-upf -o json)
+if [[ "$(echo "$OUT" | jq -r '.metadata.annotations["5g.kkarczmarek.dev/sst"]')" == "1" ]] \
+  && [[ "$(echo "$OUT" | jq -r '.metadata.annotations["5g.kkarczmarek.dev/ue-pool-cidr"]')" == "10.60.0.0/16" ]] \
+  && [[ "$(echo "$OUT" | jq -r '.metadata.labels["5g.kkarczmarek.dev/slice-id"]')" == "1" ]]; then
+  log "[OK] anotacje/labele slice'a uzupełnione z NetworkSlice."
+else
+  log "[BŁĄD] brak anotacji slice'a na Podzie:"
+  echo "$OUT" | jq '.metadata.annotations'
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: nieznany slice i sprzeczne anotacje – DENY
+# -------------------------------------------------------------------
+log "==[TC-SLICE-1] Krok 3: Pod z nieznanym slice'em – oczekuję DENY =="
+if "${KUBECTL[@]}" -n "$NS" run slice1-bad --image=docker.io/library/busybox:1.36 \
+    --labels=nf=smf,project=free5gc --annotations=5g.kkarczmarek.dev/network-slice=nie-ma-takiego \
+    --dry-run=server -- sleep 3600 >/dev/null 2>cm.err; then
+  log "[BŁĄD] referencja do nieznanego slice'a została PRZYJĘTA!"
+else
+  log "[OK] referencja do nieznanego slice'a została ODRZUCONA:"
+  cat cm.err
+fi
+echo
+log "==[TC-SLICE-1] Krok 3b: Pod z sst=2 i DNN ims sprzecznymi ze slice'em – oczekuję DENY =="
+if "${KUBECTL[@]}" -n "$NS" run slice1-bad --image=docker.io/library/busybox:1.36 \
+    --labels=nf=smf,project=free5gc \
+    --annotations=5g.kkarczmarek.dev/network-slice=slice1-embb \
+    --annotations=5g.kkarczmarek.dev/sst=2 --annotations=5g.kkarczmarek.dev/dnn=ims \
+    --dry-run=server -- sleep 3600 >/dev/null 2>cm.err; then
+  log "[BŁĄD] anotacje sprzeczne ze slice'em zostały PRZYJĘTE!"
+else
+  log "[OK] anotacje sprzeczne ze slice'em zostały ODRZUCONE:"
+  cat cm.err
+fi
+rm -f cm.err
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: status slice'a – UPF podpięty
+# -------------------------------------------------------------------
+log "==[TC-SLICE-1] Krok 4: status NetworkSlice – oczekuję boundUPFs=1 =="
+"${KUBECTL[@]}" -n "$NS" wait pod/slice1-upf --for=condition=Ready --timeout=60s >/dev/null || true
+BOUND=""
+for _ in $(seq 1 20); do
+  BOUND=$("${KUBECTL[@]}" -n "$NS" get networkslice slice1-embb -o jsonpath='{.status.boundUPFs}')
+  [[ "$BOUND" == "1" ]] && break
+  sleep 3
+done
+"${KUBECTL[@]}" -n "$NS" get networkslice slice1-embb
+if [[ "$BOUND" == "1" ]]; then
+  log "[OK] status.boundUPFs=1 (UPF slice1-upf podpięty)."
+else
+  log "[BŁĄD] status.boundUPFs='$BOUND', oczekiwano 1!"
+fi
+
+echo
+divider
+log "==[TC-SLICE-1] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete pod slice1-upf --ignore-not-found=true >/dev/null 2>&1 || true
+"${KUBECTL[@]}" -n "$NS" delete networkslice slice1-embb --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-SLICE-1] KONIEC TESTU =="
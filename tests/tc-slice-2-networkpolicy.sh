@@ -0,0 +1,179 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="free5gc"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-SLICE-2] NetworkPolicy izolujące slice'y (slice-isolation-<slice-id>) =="
+
+# funkcja jest domyślnie wyłączona w k8s/30-webhook-deploy-svc.yaml
+ENABLED=$("${KUBECTL[@]}" -n admission-system get deploy admission-webhook \
+  -o jsonpath='{.spec.template.spec.containers[0].env[?(@.name=="SLICE_NETWORK_POLICIES")].value}' 2>/dev/null || true)
+if [[ "$ENABLED" != "true" ]]; then
+  log "[POMINIĘTO] SLICE_NETWORK_POLICIES nie jest włączone w admission-webhook."
+  log "==[TC-SLICE-2] KONIEC TESTU =="
+  exit 0
+fi
+
+echo
+log "==[TC-SLICE-2] Sprzątanie starych Podów (slice2-*) =="
+"${KUBECTL[@]}" -n "$NS" delete pod slice2-a slice2-b slice2-amf slice2-udm slice2-gnb \
+  --ignore-not-found=true --wait=true >/dev/null 2>&1 || true
+divider
+
+NETSHOOT="docker.io/nicolaka/netshoot:v0.13"
+
+pod() {
+  cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: smf
+  annotations:
+    5g.kkarczmarek.dev/slice-id: "$2"
+spec:
+  containers:
+  - name: smf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# wait_np <nazwa> <present|absent>
+wait_np() {
+  for _ in $(seq 1 20); do
+    if "${KUBECTL[@]}" -n "$NS" get networkpolicy "$1" >/dev/null 2>&1; then
+      [[ "$2" == "present" ]] && return 0
+    else
+      [[ "$2" == "absent" ]] && return 0
+    fi
+    sleep 3
+  done
+  return 1
+}
+
+# -------------------------------------------------------------------
+# KROK 1: Pody w slice'ach s2a i s2b – po jednej polityce na slice
+# -------------------------------------------------------------------
+log "==[TC-SLICE-2] Krok 1: Pody slice-id=s2a i slice-id=s2b – oczekuję dwóch NetworkPolicy =="
+pod slice2-a s2a
+pod slice2-b s2b
+if wait_np slice-isolation-s2a present && wait_np slice-isolation-s2b present; then
+  log "[OK] polityki slice-isolation-s2a i slice-isolation-s2b utworzone."
+  "${KUBECTL[@]}" -n "$NS" get networkpolicy -l app.kubernetes.io/managed-by=admission-webhook
+else
+  log "[BŁĄD] brak polityk slice-isolation-*!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: polityka wybiera tylko Pody swojego slice'a
+# -------------------------------------------------------------------
+log "==[TC-SLICE-2] Krok 2: podSelector i egress polityki s2a =="
+NP=$("${KUBECTL[@]}" -n "$NS" get networkpolicy slice-isolation-s2a -o json 2>/dev/null || echo '{}')
+if [[ "$(echo "$NP" | jq -r '.spec.podSelector.matchLabels["5g.kkarczmarek.dev/slice-id"]')" == "s2a" ]] \
+  && [[ "$(echo "$NP" | jq -r '[.spec.egress[].to[]?.podSelector.matchExpressions[]?.values[]?] | index("udm")')" != "null" ]]; then
+  log "[OK] polityka wybiera slice-id=s2a, egress SBI do UDM."
+else
+  log "[BŁĄD] nieoczekiwana polityka:"
+  echo "$NP" | jq '.spec.podSelector, .spec.egress'
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: ruch na żywo do AMF-a slice'a s2a: N2 (SCTP 38412) od gNB,
+# SBI od współdzielonego UDM, SBI od Poda spoza list – zablokowane
+# -------------------------------------------------------------------
+log "==[TC-SLICE-2] Krok 3: AMF w slice'u s2a – N2 od gNB i SBI od UDM przechodzą, SBI od innego Poda nie =="
+# client <name> <labels-yaml> – Pod bez slice-id (polityki go nie obejmują)
+client() {
+  cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+$2
+spec:
+  containers:
+  - name: client
+    image: ${NETSHOOT}
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+cat <<YAML | "${KUBECTL[@]}" -n "$NS" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: slice2-amf
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: amf
+  annotations:
+    5g.kkarczmarek.dev/slice-id: "s2a"
+spec:
+  containers:
+  - name: amf
+    image: ${NETSHOOT}
+    command: ["sh","-c","socat SCTP-LISTEN:38412,fork,reuseaddr SYSTEM:'echo n2-ok' & socat TCP-LISTEN:8000,fork,reuseaddr SYSTEM:'echo sbi-ok' & wait"]
+YAML
+client slice2-udm "    nf: udm"
+client slice2-gnb "    app: slice2-gnb"
+"${KUBECTL[@]}" -n "$NS" wait --for=condition=Ready pod/slice2-amf pod/slice2-udm pod/slice2-gnb --timeout=120s >/dev/null
+wait_np slice-n2-amf present || log "  -> brak polityki slice-n2-amf"
+AMF_IP=$("${KUBECTL[@]}" -n "$NS" get pod slice2-amf -o jsonpath='{.status.podIP}')
+
+# probe <pod> <socat-address> – odpowiedź serwera albo pusty napis
+probe() {
+  "${KUBECTL[@]}" -n "$NS" exec "$1" -- sh -c "echo | socat -T3 - $2" 2>/dev/null || true
+}
+if [[ "$(probe slice2-gnb "SCTP:${AMF_IP}:38412")" == "n2-ok" ]]; then
+  log "[OK] N2 (SCTP 38412) z gNB do AMF przechodzi."
+else
+  log "[BŁĄD] N2 z gNB do AMF zablokowane (polityka slice-n2-amf)!"
+fi
+if [[ "$(probe slice2-udm "TCP:${AMF_IP}:8000")" == "sbi-ok" ]]; then
+  log "[OK] SBI z UDM (SLICE_NETPOL_SHARED_NFS) do AMF przechodzi."
+else
+  log "[BŁĄD] SBI z UDM do AMF zablokowane!"
+fi
+if [[ -z "$(probe slice2-gnb "TCP:${AMF_IP}:8000")" ]]; then
+  log "[OK] SBI z Poda spoza list do AMF zablokowane – polityka działa."
+else
+  log "[BŁĄD] SBI z Poda spoza list do AMF przechodzi – CNI nie egzekwuje NetworkPolicy?"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: usunięcie ostatniego Poda slice'a – polityka znika
+# -------------------------------------------------------------------
+log "==[TC-SLICE-2] Krok 4: usuwam slice2-a i slice2-amf – oczekuję usunięcia slice-isolation-s2a i slice-n2-amf =="
+"${KUBECTL[@]}" -n "$NS" delete pod slice2-a slice2-amf --wait=true >/dev/null
+if wait_np slice-isolation-s2a absent && wait_np slice-n2-amf absent; then
+  log "[OK] polityki slice-isolation-s2a i slice-n2-amf usunięte."
+else
+  log "[BŁĄD] polityka slice-isolation-s2a albo slice-n2-amf nadal istnieje!"
+fi
+
+echo
+divider
+log "==[TC-SLICE-2] Sprzątanie =="
+"${KUBECTL[@]}" -n "$NS" delete pod slice2-a slice2-b slice2-amf slice2-udm slice2-gnb \
+  --ignore-not-found=true >/dev/null 2>&1 || true
+log "==[TC-SLICE-2] KONIEC TESTU =="
@@ -90,7 +90,7 @@ rm -f svc-missing-port.err
 
 ###############################################################################
 # KROK 3 – błędny format required-ports:
-#  - required-ports: "80,foo" (foo nie jest liczbą)
+#  - required-ports: "80,443/foo" (foo nie jest protokołem tcp/udp/sctp)
 #  -> OCZEKUJĘ: DENY z komunikatem o niepoprawnej liście portów
 ###############################################################################
 log
@@ -106,7 +106,7 @@ metadata:
     app.kubernetes.io/part-of: free5gc
     project: free5gc
   annotations:
-    5g.kkarczmarek.dev/required-ports: "80,foo"
+    5g.kkarczmarek.dev/required-ports: "80,443/foo"
 spec:
   selector:
     app: web
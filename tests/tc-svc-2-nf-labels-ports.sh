@@ -0,0 +1,135 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-SVC-2] Service: labele nf/slice z Podów i protokoły SCTP/UDP na portach 5G =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$NS" delete pod svc2-amf --ignore-not-found=true >/dev/null
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: Pod AMF z labelami slice'a (przez anotacje)
+# -------------------------------------------------------------------
+log "==[TC-SVC-2] Krok 1: tworzę Pod AMF (app=svc2-amf, slice-id=1) =="
+cat <<YAML | "${KUBECTL[@]}" apply -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: svc2-amf
+  namespace: ${NS}
+  labels:
+    app: svc2-amf
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: amf
+    5g.kkarczmarek.dev/slice-id: "1"
+    5g.kkarczmarek.dev/sst: "1"
+spec:
+  containers:
+  - name: amf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: Service N2 bez protokołu (apiserver nadaje TCP), bez nazwy
+# i bez labeli – dry-run
+# -------------------------------------------------------------------
+log "==[TC-SVC-2] Krok 2: Service 38412 bez protokołu – oczekuję SCTP, nazwy n2 i labeli nf/slice =="
+OUT=$(cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc2-amf-n2
+  namespace: ${NS}
+spec:
+  selector:
+    app: svc2-amf
+  ports:
+  - port: 38412
+YAML
+)
+log "  -> labels: $(echo "$OUT" | jq -c '.metadata.labels')"
+log "  -> ports:  $(echo "$OUT" | jq -c '.spec.ports')"
+if [[ "$(echo "$OUT" | jq -r '.spec.ports[0].protocol')" == "SCTP" ]] \
+  && [[ "$(echo "$OUT" | jq -r '.spec.ports[0].name')" == "n2" ]] \
+  && [[ "$(echo "$OUT" | jq -r '.metadata.labels.nf')" == "amf" ]] \
+  && [[ "$(echo "$OUT" | jq -r '.metadata.labels["5g.kkarczmarek.dev/slice-id"]')" == "1" ]]; then
+  log "[OK] Service dostał SCTP, nazwę n2 i labele z Poda."
+else
+  log "[BŁĄD] Service nie został poprawnie zmutowany!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2b: Service N2 z jawnym UDP – mutator poprawia tylko TCP, walidator
+# odrzuca
+# -------------------------------------------------------------------
+log "==[TC-SVC-2] Krok 2b: Service 38412/UDP – oczekuję DENY =="
+if cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>&1
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc2-amf-n2
+  namespace: ${NS}
+spec:
+  selector:
+    app: svc2-amf
+  ports:
+  - port: 38412
+    protocol: UDP
+YAML
+then
+  log "[BŁĄD] Service z N2 po UDP został PRZYJĘTY!"
+else
+  log "[OK] Service z N2 po UDP został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: ten sam port 2x (TCP + UDP) – TCP zostaje, walidator odrzuca
+# -------------------------------------------------------------------
+log "==[TC-SVC-2] Krok 3: Service z 8805/TCP obok 8805/UDP – oczekuję DENY =="
+if cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>&1
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc2-pfcp
+  namespace: ${NS}
+spec:
+  selector:
+    app: svc2-amf
+  ports:
+  - name: pfcp-tcp
+    port: 8805
+    protocol: TCP
+  - name: pfcp
+    port: 8805
+    protocol: UDP
+YAML
+then
+  log "[BŁĄD] Service z PFCP po TCP został PRZYJĘTY!"
+else
+  log "[OK] Service z PFCP po TCP został ODRZUCONY."
+fi
+
+"${KUBECTL[@]}" -n "$NS" delete pod svc2-amf --ignore-not-found=true --wait=false >/dev/null
+echo
+divider
+log "==[TC-SVC-2] KONIEC TESTU =="
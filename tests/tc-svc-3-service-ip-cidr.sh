@@ -0,0 +1,101 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+# zakres clusterIP MicroK8s (SERVICE_CIDRS w k8s/30-webhook-deploy-svc.yaml)
+IP_OK="10.152.183.240"
+IP_OUT="10.99.0.10"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-SVC-3] Anotacja service-ip: service CIDR, zajęte IP i niezmienny clusterIP =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$NS" delete svc svc3-a svc3-b --ignore-not-found=true >/dev/null
+divider
+
+# svc <name> <ip> [dry-run] – Service z anotacją service-ip
+svc() {
+  cat <<YAML | "${KUBECTL[@]}" apply ${3:+--dry-run=server} -f -
+apiVersion: v1
+kind: Service
+metadata:
+  name: $1
+  namespace: ${NS}
+  annotations:
+    5g.kkarczmarek.dev/service-ip: "$2"
+spec:
+  selector:
+    app: svc3
+  ports:
+  - name: http
+    port: 80
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: IP spoza service CIDR – DENY
+# -------------------------------------------------------------------
+log "==[TC-SVC-3] Krok 1: service-ip ${IP_OUT} spoza CIDR – oczekuję DENY =="
+if svc svc3-a "${IP_OUT}" dry >/dev/null 2>&1; then
+  log "[BŁĄD] Service z IP spoza service CIDR został PRZYJĘTY!"
+else
+  log "[OK] Service z IP spoza service CIDR został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: poprawne IP – ALLOW, clusterIP = anotacja
+# -------------------------------------------------------------------
+log "==[TC-SVC-3] Krok 2: service-ip ${IP_OK} – oczekuję ALLOW i clusterIP=${IP_OK} =="
+if svc svc3-a "${IP_OK}" >/dev/null; then
+  CIP=$("${KUBECTL[@]}" -n "$NS" get svc svc3-a -o jsonpath='{.spec.clusterIP}')
+  log "  -> clusterIP: ${CIP}"
+  if [[ "${CIP}" == "${IP_OK}" ]]; then
+    log "[OK] clusterIP nadany z anotacji."
+  else
+    log "[BŁĄD] clusterIP różni się od anotacji!"
+  fi
+else
+  log "[BŁĄD] poprawny Service został ODRZUCONY!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: drugi Service z tym samym IP – DENY (informer)
+# -------------------------------------------------------------------
+log "==[TC-SVC-3] Krok 3: drugi Service z ${IP_OK} – oczekuję DENY =="
+sleep 2
+if svc svc3-b "${IP_OK}" dry >/dev/null 2>&1; then
+  log "[BŁĄD] Service z zajętym IP został PRZYJĘTY!"
+else
+  log "[OK] Service z zajętym IP został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: UPDATE anotacji na inne IP – DENY (clusterIP niezmienny)
+# -------------------------------------------------------------------
+log "==[TC-SVC-3] Krok 4: zmiana service-ip istniejącego Service'u – oczekuję DENY =="
+if "${KUBECTL[@]}" -n "$NS" annotate svc svc3-a --overwrite \
+  5g.kkarczmarek.dev/service-ip=10.152.183.241 >/dev/null 2>&1; then
+  log "[BŁĄD] zmiana service-ip została PRZYJĘTA!"
+else
+  log "[OK] zmiana service-ip została ODRZUCONA."
+fi
+
+"${KUBECTL[@]}" -n "$NS" delete svc svc3-a --ignore-not-found=true >/dev/null
+echo
+divider
+log "==[TC-SVC-3] KONIEC TESTU =="
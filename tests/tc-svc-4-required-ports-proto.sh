@@ -0,0 +1,101 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-SVC-4] required-ports z protokołem, nazwanymi portami i zakresami =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+divider
+
+# pod <name> <required-ports> <ports-yaml> – server-side dry-run, nf bez profilu portów
+pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>&1
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: pcf
+  annotations:
+    5g.kkarczmarek.dev/required-ports: "$2"
+spec:
+  containers:
+  - name: pcf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+    ports:
+$3
+YAML
+}
+
+# expect <ALLOW|DENY> <opis> <pod-args...>
+expect() {
+  local want="$1" desc="$2"
+  shift 2
+  local got=DENY
+  if pod "$@"; then
+    got=ALLOW
+  fi
+  if [[ "$got" == "$want" ]]; then
+    log "[OK] ${desc}: ${got}"
+  else
+    log "[BŁĄD] ${desc}: oczekiwano ${want}, jest ${got}"
+  fi
+}
+
+PFCP_TCP='    - containerPort: 8805
+      protocol: TCP'
+PFCP_UDP='    - name: pfcp
+      containerPort: 8805
+      protocol: UDP'
+RANGE='    - containerPort: 30000
+      protocol: UDP
+    - containerPort: 30001
+      protocol: UDP'
+
+# -------------------------------------------------------------------
+# KROK 1: protokół
+# -------------------------------------------------------------------
+log "==[TC-SVC-4] Krok 1: 8805 po TCP nie spełnia wymagania PFCP =="
+expect DENY  "8805/udp vs 8805/TCP" rp-1 "8805/udp" "$PFCP_TCP"
+expect DENY  "8805 (domyślnie UDP) vs 8805/TCP" rp-2 "8805" "$PFCP_TCP"
+expect ALLOW "8805/udp vs 8805/UDP" rp-3 "8805/udp" "$PFCP_UDP"
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: nazwane porty i zakresy
+# -------------------------------------------------------------------
+log "==[TC-SVC-4] Krok 2: nazwane porty i zakresy =="
+expect ALLOW "nazwany pfcp" rp-4 "pfcp" "$PFCP_UDP"
+expect DENY  "nazwany sbi (brak)" rp-5 "sbi" "$PFCP_UDP"
+expect ALLOW "zakres 30000-30001/udp" rp-6 "30000-30001/udp" "$RANGE"
+expect DENY  "zakres 30000-30002/udp (brak 30002)" rp-7 "30000-30002/udp" "$RANGE"
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: format
+# -------------------------------------------------------------------
+log "==[TC-SVC-4] Krok 3: błędny protokół i za duży zakres =="
+expect DENY "8805/icmp" rp-8 "8805/icmp" "$PFCP_UDP"
+expect DENY "1-65535" rp-9 "1-65535" "$PFCP_UDP"
+
+echo
+divider
+log "==[TC-SVC-4] KONIEC TESTU =="
@@ -0,0 +1,99 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-10] Rozmieszczenie UPF: node affinity, tolerancje, anti-affinity =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+divider
+
+# upf_deploy <name> <extra-spec> – Deployment UPF po mutacji (server-side dry-run)
+upf_deploy() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: $1
+  template:
+    metadata:
+      labels:
+        app: $1
+        nf: upf
+        app.kubernetes.io/part-of: free5gc
+        project: free5gc
+      annotations:
+        5g.kkarczmarek.dev/slice-id: "slice-a"
+    spec:
+$2
+      containers:
+      - name: upf
+        image: docker.io/library/busybox:1.36
+        command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: UPF bez własnego schedulingu – webhook dopisuje affinity
+# -------------------------------------------------------------------
+log "==[TC-UPF-10] Krok 1: UPF bez nodeSelector – oczekuję node affinity upf=enabled =="
+OUT=$(upf_deploy upf-placement-auto "")
+NODE_AFF=$(echo "$OUT" | jq -c '.spec.template.spec.affinity.nodeAffinity.requiredDuringSchedulingIgnoredDuringExecution.nodeSelectorTerms')
+ANTI=$(echo "$OUT" | jq -c '.spec.template.spec.affinity.podAntiAffinity')
+SPREAD=$(echo "$OUT" | jq -c '.spec.template.spec.topologySpreadConstraints')
+log "  -> nodeAffinity: ${NODE_AFF}"
+log "  -> podAntiAffinity: ${ANTI}"
+log "  -> topologySpreadConstraints: ${SPREAD}"
+
+if echo "$NODE_AFF" | grep -q '"key":"upf"'; then
+  log "[OK] dopisano node affinity do węzłów UPF."
+else
+  log "[BŁĄD] brak node affinity!"
+fi
+if echo "$ANTI" | grep -q 'slice-id'; then
+  log "[OK] anti-affinity obejmuje repliki tego samego slice'a."
+else
+  log "[BŁĄD] brak anti-affinity po slice-id!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: jawny nodeSelector użytkownika zostaje nietknięty
+# -------------------------------------------------------------------
+log "==[TC-UPF-10] Krok 2: UPF z własnym nodeSelector – oczekuję braku node affinity =="
+OUT=$(upf_deploy upf-placement-manual "      nodeSelector:
+        kubernetes.io/hostname: workernode")
+NODE_AFF=$(echo "$OUT" | jq -c '.spec.template.spec.affinity.nodeAffinity // empty')
+SEL=$(echo "$OUT" | jq -c '.spec.template.spec.nodeSelector')
+log "  -> nodeSelector: ${SEL}"
+if [ -z "$NODE_AFF" ]; then
+  log "[OK] webhook nie nadpisał schedulingu użytkownika."
+else
+  log "[BŁĄD] webhook dopisał node affinity mimo nodeSelector: ${NODE_AFF}"
+fi
+
+echo
+divider
+log "==[TC-UPF-10] KONIEC TESTU =="
@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+GTP5G_LABEL_KEY="${GTP5G_LABEL_KEY:-gtp5g}"
+GTP5G_LABEL_VALUE="${GTP5G_LABEL_VALUE:-loaded}"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-11] gtp5g: init container gtp5g-check + nodeSelector tylko na węzły z gtp5g =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+
+log "  -> węzły z labelem ${GTP5G_LABEL_KEY}=${GTP5G_LABEL_VALUE}:"
+"${KUBECTL[@]}" get nodes -l "${GTP5G_LABEL_KEY}=${GTP5G_LABEL_VALUE}" --no-headers -o custom-columns=NAME:.metadata.name | sed 's/^/     /'
+divider
+
+# upf_pod <name> <nodeSelector-yaml> – Pod UPF (server-side dry-run)
+upf_pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+spec:
+  nodeSelector:
+$2
+  containers:
+  - name: upf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: nodeSelector z labelem gtp5g – ALLOW + init container
+# -------------------------------------------------------------------
+log "==[TC-UPF-11] Krok 1: nodeSelector ${GTP5G_LABEL_KEY}=${GTP5G_LABEL_VALUE} – oczekuję ALLOW =="
+if OUT=$(upf_pod upf-gtp5g-ok "    ${GTP5G_LABEL_KEY}: \"${GTP5G_LABEL_VALUE}\"" 2>&1); then
+  INIT=$(echo "$OUT" | jq -r '[.spec.initContainers[]?.name] | join(",")')
+  log "  -> initContainers: ${INIT}"
+  if echo "$INIT" | grep -q '^gtp5g-check'; then
+    log "[OK] Pod przyjęty, gtp5g-check jest pierwszym init containerem."
+  else
+    log "[BŁĄD] brak init containera gtp5g-check (GTP5G_CHECK=true?)"
+  fi
+else
+  log "[BŁĄD] Pod odrzucony: ${OUT}"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: nodeSelector obejmujący węzły bez gtp5g – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-11] Krok 2: nodeSelector kubernetes.io/os=linux – oczekuję DENY =="
+if upf_pod upf-gtp5g-bad "    kubernetes.io/os: linux" >/dev/null 2>&1; then
+  if [ "$("${KUBECTL[@]}" get nodes --no-headers | wc -l)" = \
+       "$("${KUBECTL[@]}" get nodes -l "${GTP5G_LABEL_KEY}=${GTP5G_LABEL_VALUE}" --no-headers | wc -l)" ]; then
+    log "[OK] Pod przyjęty – wszystkie węzły mają gtp5g."
+  else
+    log "[BŁĄD] Pod przyjęty mimo węzłów bez gtp5g!"
+  fi
+else
+  log "[OK] Pod z nodeSelector na węzły bez gtp5g został ODRZUCONY."
+fi
+
+echo
+divider
+log "==[TC-UPF-11] KONIEC TESTU =="
@@ -0,0 +1,137 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-12] Sysctle UPF (ip_forward, rp_filter) i blokada niebezpiecznych sysctli =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$NS" delete pod upf-sysctls-live --ignore-not-found=true --wait=true >/dev/null
+divider
+
+# webhook_env <UPF_SYSCTLS> <UPF_ALLOWED_SYSCTLS> – zmiana env i restart webhooka
+webhook_env() {
+  "${KUBECTL[@]}" -n admission-system set env deploy/admission-webhook \
+    "UPF_SYSCTLS=$1" "UPF_ALLOWED_SYSCTLS=$2" >/dev/null
+  "${KUBECTL[@]}" -n admission-system rollout status deploy/admission-webhook --timeout=120s >/dev/null
+}
+
+# pod <name> <nf> <annotations-yaml> <securityContext-yaml> – server-side dry-run
+pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: $2
+  annotations:
+    demo: "true"
+$3
+spec:
+  securityContext:
+$4
+  containers:
+  - name: $2
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: prawdziwy Pod UPF z wdrożoną konfiguracją – kubelet go uruchamia
+# (domyślnie bez sysctli spoza safe setu, więc bez SysctlForbidden)
+# -------------------------------------------------------------------
+log "==[TC-UPF-12] Krok 1: Pod UPF uruchomiony na węźle – oczekuję Ready (bez SysctlForbidden) =="
+cat <<YAML | "${KUBECTL[@]}" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: upf-sysctls-live
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+spec:
+  containers:
+  - name: upf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+if "${KUBECTL[@]}" -n "$NS" wait --for=condition=Ready pod/upf-sysctls-live --timeout=120s >/dev/null 2>&1; then
+  log "[OK] Pod UPF działa, sysctls: $("${KUBECTL[@]}" -n "$NS" get pod upf-sysctls-live -o jsonpath='{.spec.securityContext.sysctls}')"
+else
+  log "[BŁĄD] Pod UPF nie wystartował: $("${KUBECTL[@]}" -n "$NS" get pod upf-sysctls-live \
+    -o jsonpath='{.status.phase} {.status.reason} {.status.message}')"
+fi
+"${KUBECTL[@]}" -n "$NS" delete pod upf-sysctls-live --ignore-not-found=true >/dev/null
+divider
+
+# kolejne kroki: opt-in (server-side dry-run, więc kubelet ich nie ocenia)
+ORIG_SYSCTLS=$("${KUBECTL[@]}" -n admission-system get deploy admission-webhook \
+  -o jsonpath='{.spec.template.spec.containers[0].env[?(@.name=="UPF_SYSCTLS")].value}')
+ORIG_ALLOWED=$("${KUBECTL[@]}" -n admission-system get deploy admission-webhook \
+  -o jsonpath='{.spec.template.spec.containers[0].env[?(@.name=="UPF_ALLOWED_SYSCTLS")].value}')
+webhook_env "net.ipv4.ip_forward=1" "net.ipv4.ip_forward,net.ipv4.conf.*.rp_filter"
+log "  -> webhook: UPF_SYSCTLS=net.ipv4.ip_forward=1, UPF_ALLOWED_SYSCTLS=net.ipv4.ip_forward,net.ipv4.conf.*.rp_filter"
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: UPF – ip_forward z UPF_SYSCTLS + rp_filter z anotacji
+# -------------------------------------------------------------------
+log "==[TC-UPF-12] Krok 2: UPF z anotacją sysctls – oczekuję ip_forward=1 i rp_filter=0 =="
+OUT=$(pod upf-sysctls upf '    5g.kkarczmarek.dev/sysctls: "net.ipv4.conf.all.rp_filter=0"' "")
+SYSCTLS=$(echo "$OUT" | jq -c '.spec.securityContext.sysctls')
+log "  -> sysctls: ${SYSCTLS}"
+if echo "$SYSCTLS" | grep -q 'net.ipv4.ip_forward' && echo "$SYSCTLS" | grep -q 'rp_filter'; then
+  log "[OK] webhook dopisał sysctle UPF."
+else
+  log "[BŁĄD] brak oczekiwanych sysctli!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: zwykły NF z ip_forward – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-12] Krok 3: AMF z net.ipv4.ip_forward – oczekuję DENY =="
+if pod amf-sysctls amf "" '    sysctls:
+    - name: net.ipv4.ip_forward
+      value: "1"' >/dev/null 2>&1; then
+  log "[BŁĄD] AMF z niebezpiecznym sysctlem został PRZYJĘTY!"
+else
+  log "[OK] AMF z niebezpiecznym sysctlem został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 4: UPF z sysctlem spoza allowlisty – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-12] Krok 4: UPF z kernel.msgmax – oczekuję DENY =="
+if pod upf-sysctls-bad upf '    5g.kkarczmarek.dev/sysctls: "kernel.msgmax=65536"' "" >/dev/null 2>&1; then
+  log "[BŁĄD] UPF z sysctlem spoza UPF_ALLOWED_SYSCTLS został PRZYJĘTY!"
+else
+  log "[OK] UPF z sysctlem spoza allowlisty został ODRZUCONY."
+fi
+
+echo
+divider
+log "==[TC-UPF-12] Przywracam konfigurację webhooka =="
+webhook_env "$ORIG_SYSCTLS" "$ORIG_ALLOWED"
+log "==[TC-UPF-12] KONIEC TESTU =="
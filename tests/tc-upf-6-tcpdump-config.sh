@@ -0,0 +1,102 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-6] Konfiguracja sidecara tcpdump przez anotacje (filtr BPF, rotacja) =="
+
+log "==[TC-UPF-6] Krok 0: namespace ${NS} z labelami webhooka i NET_ADMIN =="
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" label ns "$NS" allow-netadmin=true --overwrite >/dev/null
+divider
+
+# upf_pod <name> <filter> <rotate-size> <max-files> – manifest Poda UPF z tcpdumpem
+upf_pod() {
+  cat <<YAML
+apiVersion: v1
+kind: Pod
+metadata:
+  name: $1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+  annotations:
+    5g.kkarczmarek.dev/tcpdump-enabled: "true"
+    5g.kkarczmarek.dev/tcpdump-interface: "any"
+    5g.kkarczmarek.dev/tcpdump-filter: "$2"
+    5g.kkarczmarek.dev/tcpdump-snaplen: "256"
+    5g.kkarczmarek.dev/tcpdump-rotate-size: "$3"
+    5g.kkarczmarek.dev/tcpdump-max-files: "$4"
+spec:
+  containers:
+  - name: main
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+}
+
+# -------------------------------------------------------------------
+# KROK 1: poprawna konfiguracja – argumenty tcpdumpa i sizeLimit volume
+# -------------------------------------------------------------------
+log "==[TC-UPF-6] Krok 1: filtr 'udp port 2152 or udp port 8805', 10MB x 3 pliki =="
+OUT=$(upf_pod upf-tcpdump-cfg "udp port 2152 or udp port 8805" 10 3 |
+  "${KUBECTL[@]}" apply --dry-run=server -o json -f -)
+
+ARGS=$(echo "$OUT" | jq -c '.spec.containers[] | select(.name=="tcpdump-sidecar") | .args')
+LIMIT=$(echo "$OUT" | jq -r '.spec.volumes[] | select(.name=="tcpdump-data") | .emptyDir.sizeLimit')
+log "  -> args: ${ARGS}"
+log "  -> sizeLimit: ${LIMIT}"
+
+if echo "$ARGS" | grep -q '"-C","10","-W","3"' && echo "$ARGS" | grep -q '"udp port 2152 or udp port 8805"'; then
+  log "[OK] sidecar ma ring buffer -C/-W i filtr BPF."
+else
+  log "[BŁĄD] nieoczekiwane argumenty sidecara!"
+fi
+if [ "$LIMIT" = "33Mi" ]; then
+  log "[OK] volume tcpdump-data ma sizeLimit 33Mi (10MB x 3 + 10%)."
+else
+  log "[BŁĄD] oczekiwano sizeLimit 33Mi, jest: ${LIMIT}"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: filtr ze znakami powłoki – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-6] Krok 2: filtr 'host \$(id)' – oczekuję DENY =="
+if upf_pod upf-tcpdump-bad-filter 'host $(id)' 10 3 |
+  "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>&1; then
+  log "[BŁĄD] Pod z niepoprawnym filtrem został PRZYJĘTY!"
+else
+  log "[OK] Pod z niepoprawnym filtrem został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: ring buffer ponad TCPDUMP_MAX_TOTAL_MB – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-6] Krok 3: 1000MB x 50 plików – oczekuję DENY =="
+if upf_pod upf-tcpdump-too-big "udp" 1000 50 |
+  "${KUBECTL[@]}" apply --dry-run=server -f - >/dev/null 2>&1; then
+  log "[BŁĄD] Pod z za dużym ring bufferem został PRZYJĘTY!"
+else
+  log "[OK] Pod z za dużym ring bufferem został ODRZUCONY."
+fi
+
+echo
+divider
+log "==[TC-UPF-6] KONIEC TESTU =="
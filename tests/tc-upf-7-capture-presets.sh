@@ -0,0 +1,79 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-7] Presety przechwytywania tcpdump (pfcp / gtpu / n6-ue-pool / all) =="
+
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" label ns "$NS" allow-netadmin=true --overwrite >/dev/null
+divider
+
+# preset_args <preset> [ue-pool-cidr] – argumenty sidecara po mutacji (server-side dry-run)
+preset_args() {
+  cat <<YAML | "${KUBECTL[@]}" apply --dry-run=server -o json -f - 2>/dev/null |
+apiVersion: v1
+kind: Pod
+metadata:
+  name: upf-preset-$1
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+  annotations:
+    5g.kkarczmarek.dev/tcpdump-enabled: "true"
+    5g.kkarczmarek.dev/tcpdump-preset: "$1"
+    5g.kkarczmarek.dev/networks: "n3-net@10.100.20.5/24,n4-net@10.100.30.5/24,n6-net@10.100.10.5/24"
+    5g.kkarczmarek.dev/ue-pool-cidr: "${2:-}"
+spec:
+  containers:
+  - name: main
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+    jq -c '.spec.containers[] | select(.name=="tcpdump-sidecar") | .args'
+}
+
+# expect <preset> <ue-pool> <oczekiwany fragment args>
+expect() {
+  local args
+  args=$(preset_args "$1" "$2" || true)
+  log "  -> preset $1: ${args:-<odrzucony>}"
+  if [ -n "$args" ] && echo "$args" | grep -qF -- "$3"; then
+    log "[OK] preset $1 rozwinięty poprawnie."
+  else
+    log "[BŁĄD] preset $1: oczekiwano fragmentu $3"
+  fi
+  divider
+}
+
+expect pfcp "" '"-i","n4"'
+expect pfcp "" '"udp port 8805 and host 10.100.30.5"'
+expect gtpu "" '"udp port 2152 and host 10.100.20.5"'
+expect n6-ue-pool "10.60.0.0/16" '"net 10.60.0.0/16"'
+expect all "10.60.0.0/16" '"-i","any"'
+
+log "==[TC-UPF-7] n6-ue-pool bez ue-pool-cidr – oczekuję DENY =="
+if [ -n "$(preset_args n6-ue-pool "" || true)" ]; then
+  log "[BŁĄD] Pod z presetem n6-ue-pool bez ue-pool-cidr został PRZYJĘTY!"
+else
+  log "[OK] Pod został ODRZUCONY przez webhook."
+fi
+
+echo
+divider
+log "==[TC-UPF-7] KONIEC TESTU =="
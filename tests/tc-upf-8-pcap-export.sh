@@ -0,0 +1,119 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+PVC="upf-pcaps"
+POD="upf-pcap-export"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-8] Eksport pcapów: sidecar pcap-server + wolumen PVC =="
+
+# -------------------------------------------------------------------
+# KROK 0: namespace + PVC na pcapy
+# -------------------------------------------------------------------
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" label ns "$NS" allow-netadmin=true --overwrite >/dev/null
+
+cat <<YAML | "${KUBECTL[@]}" apply -f - >/dev/null
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: ${PVC}
+  namespace: ${NS}
+spec:
+  accessModes: ["ReadWriteOnce"]
+  resources:
+    requests:
+      storage: 1Gi
+YAML
+"${KUBECTL[@]}" -n "$NS" delete pod "$POD" --ignore-not-found=true >/dev/null
+log "  -> PVC ${PVC} gotowy"
+divider
+
+# create_pod – Pod UPF z tcpdump-export=true i tcpdump-volume=pvc
+create_pod() {
+  cat <<YAML | "${KUBECTL[@]}" apply -f -
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ${POD}
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+  annotations:
+    5g.kkarczmarek.dev/tcpdump-enabled: "true"
+    5g.kkarczmarek.dev/tcpdump-export: "true"
+    5g.kkarczmarek.dev/tcpdump-volume: "pvc"
+    5g.kkarczmarek.dev/tcpdump-pvc: "${PVC}"
+    5g.kkarczmarek.dev/tcpdump-rotate-size: "1"
+spec:
+  containers:
+  - name: main
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","while true; do ping -c 1 -W 1 8.8.8.8 >/dev/null 2>&1; sleep 1; done"]
+YAML
+  "${KUBECTL[@]}" -n "$NS" wait --for=condition=Ready "pod/${POD}" --timeout=120s >/dev/null
+}
+
+# -------------------------------------------------------------------
+# KROK 1: Pod UPF z eksportem pcapów
+# -------------------------------------------------------------------
+log "==[TC-UPF-8] Krok 1: Pod UPF z eksportem pcapów na PVC =="
+create_pod
+log "  -> Kontenery:"
+"${KUBECTL[@]}" -n "$NS" get pod "$POD" -o jsonpath='{range .spec.containers[*]}{"\n  - "}{.name}{end}'
+echo
+if "${KUBECTL[@]}" -n "$NS" get pod "$POD" -o jsonpath='{.spec.initContainers[*].name}' | grep -qw pcap-retention; then
+  log "[OK] init container pcap-retention (TCPDUMP_PVC_RETENTION) dodany:"
+  "${KUBECTL[@]}" -n "$NS" logs "$POD" -c pcap-retention | sed 's/^/  /'
+else
+  log "[BŁĄD] brak init containera pcap-retention w trybie PVC!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: lista i pobranie plików przez port-forward
+# -------------------------------------------------------------------
+log "==[TC-UPF-8] Krok 2: pobranie pcapów przez API pcap-server =="
+sleep 10
+OUT="$(mktemp -d)"
+KUBECTL="${KUBECTL[*]}" "$(dirname "$0")/../tools/fetch-pcaps.sh" "$NS" "$POD" "$OUT"
+
+if find "$OUT" -name 'trace.pcap*' -size +0 | grep -q .; then
+  log "[OK] pliki pcap pobrane do ${OUT}."
+else
+  log "[BŁĄD] nie udało się pobrać żadnego pliku pcap!"
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: po restarcie Poda pliki nadal są na PVC
+# -------------------------------------------------------------------
+log "==[TC-UPF-8] Krok 3: restart Poda – pliki zostają na PVC =="
+"${KUBECTL[@]}" -n "$NS" delete pod "$POD" --wait=true >/dev/null
+create_pod >/dev/null
+
+LISTED=$(KUBECTL="${KUBECTL[*]}" "$(dirname "$0")/../tools/fetch-pcaps.sh" "$NS" "$POD" "$OUT/after" | wc -l)
+if [ "$LISTED" -gt 0 ]; then
+  log "[OK] po restarcie pcap-server widzi ${LISTED} plików na PVC."
+else
+  log "[BŁĄD] po restarcie brak plików pcap!"
+fi
+
+echo
+divider
+log "==[TC-UPF-8] KONIEC TESTU =="
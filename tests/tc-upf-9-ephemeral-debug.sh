@@ -0,0 +1,100 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Używamy microk8s kubectl
+KUBECTL=(microk8s kubectl)
+NS="perf-upf"
+POD="upf-debug-target"
+# krótka nazwa celowo – webhook normalizuje ją do docker.io/nicolaka/netshoot:
+DEBUG_IMAGE="${DEBUG_IMAGE:-nicolaka/netshoot:v0.13}"
+
+log() {
+  echo "$@"
+}
+
+divider() {
+  echo "------------------------------------------------------------------"
+}
+
+log "==[TC-UPF-9] Kontenery efemeryczne (kubectl debug) dla działającego UPF =="
+
+# -------------------------------------------------------------------
+# KROK 0: działający Pod UPF z anotacjami slice'a
+# -------------------------------------------------------------------
+if ! "${KUBECTL[@]}" get ns "$NS" >/dev/null 2>&1; then
+  "${KUBECTL[@]}" create ns "$NS"
+fi
+"${KUBECTL[@]}" label ns "$NS" admission.kkarczmarek.dev/enabled=true --overwrite >/dev/null
+"${KUBECTL[@]}" label ns "$NS" allow-netadmin=true --overwrite >/dev/null
+"${KUBECTL[@]}" -n "$NS" delete pod "$POD" --ignore-not-found=true >/dev/null
+
+cat <<YAML | "${KUBECTL[@]}" apply -f - >/dev/null
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ${POD}
+  namespace: ${NS}
+  labels:
+    app.kubernetes.io/part-of: free5gc
+    project: free5gc
+    nf: upf
+  annotations:
+    5g.kkarczmarek.dev/slice-id: "slice-a"
+    5g.kkarczmarek.dev/sst: "1"
+    5g.kkarczmarek.dev/sd: "010203"
+    5g.kkarczmarek.dev/dnn: "internet"
+spec:
+  containers:
+  - name: upf
+    image: docker.io/library/busybox:1.36
+    command: ["sh","-c","sleep 3600"]
+YAML
+"${KUBECTL[@]}" -n "$NS" wait --for=condition=Ready "pod/${POD}" --timeout=120s >/dev/null
+log "  -> Pod ${POD} działa"
+divider
+
+# -------------------------------------------------------------------
+# KROK 1: użytkownik spoza DEBUG_ALLOWED_GROUPS – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-9] Krok 1: kubectl debug jako zwykły użytkownik – oczekuję DENY =="
+if "${KUBECTL[@]}" -n "$NS" debug "$POD" --as=bob --as-group=system:authenticated \
+  --image="$DEBUG_IMAGE" --target=upf --profile=netadmin -- sleep 600 >/dev/null 2>&1; then
+  log "[BŁĄD] kontener debug został DODANY przez nieuprawnionego użytkownika!"
+else
+  log "[OK] webhook (albo RBAC) ODRZUCIŁ kontener debug."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 2: obraz spoza DEBUG_IMAGES – DENY
+# -------------------------------------------------------------------
+log "==[TC-UPF-9] Krok 2: niezatwierdzony obraz – oczekuję DENY =="
+if "${KUBECTL[@]}" -n "$NS" debug "$POD" --image=docker.io/library/alpine:3.19 \
+  --target=upf --profile=netadmin -- sleep 600 >/dev/null 2>&1; then
+  log "[BŁĄD] kontener z niezatwierdzonym obrazem został DODANY!"
+else
+  log "[OK] kontener z niezatwierdzonym obrazem został ODRZUCONY."
+fi
+divider
+
+# -------------------------------------------------------------------
+# KROK 3: zatwierdzony obraz z NET_ADMIN/NET_RAW – ALLOW + env slice'a
+# -------------------------------------------------------------------
+log "==[TC-UPF-9] Krok 3: zatwierdzony obraz (profil netadmin) – oczekuję ALLOW =="
+if "${KUBECTL[@]}" -n "$NS" debug "$POD" --image="$DEBUG_IMAGE" --container=debug-tcpdump \
+  --target=upf --profile=netadmin -- sleep 600 >/dev/null 2>&1; then
+  ENV=$("${KUBECTL[@]}" -n "$NS" get pod "$POD" \
+    -o jsonpath='{.spec.ephemeralContainers[?(@.name=="debug-tcpdump")].env[*].name}')
+  log "  -> env kontenera debug: ${ENV}"
+  if echo "$ENV" | grep -q UPF_SLICE_ID; then
+    log "[OK] kontener debug dodany i ma zmienne slice'a."
+  else
+    log "[BŁĄD] kontener debug nie ma zmiennych UPF_*!"
+  fi
+else
+  log "[BŁĄD] kontener debug z zatwierdzonego obrazu został ODRZUCONY!"
+fi
+
+echo
+divider
+log "==[TC-UPF-9] KONIEC TESTU =="
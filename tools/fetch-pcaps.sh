@@ -0,0 +1,36 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Pobiera wszystkie pliki pcap z sidecara pcap-server (anotacja
+# 5g.kkarczmarek.dev/tcpdump-export=true) przez kubectl port-forward.
+#
+#   tools/fetch-pcaps.sh <namespace> <pod> [katalog-docelowy]
+
+KUBECTL=(${KUBECTL:-microk8s kubectl})
+NS="${1:?namespace}"
+POD="${2:?pod}"
+OUT="${3:-./pcaps-${POD}}"
+LPORT="${LPORT:-18090}"
+
+mkdir -p "$OUT"
+
+"${KUBECTL[@]}" -n "$NS" port-forward "pod/${POD}" "${LPORT}:8090" >/dev/null &
+PF_PID=$!
+trap 'kill "$PF_PID" 2>/dev/null || true' EXIT
+
+for _ in $(seq 1 20); do
+  curl -sf "http://127.0.0.1:${LPORT}/healthz" >/dev/null && break
+  sleep 0.5
+done
+
+FILES=$(curl -sf "http://127.0.0.1:${LPORT}/pcaps" | jq -r '.[].path')
+if [ -z "$FILES" ]; then
+  echo "Brak plików pcap w ${NS}/${POD}"
+  exit 0
+fi
+
+for f in $FILES; do
+  mkdir -p "$OUT/$(dirname "$f")"
+  curl -sf -o "$OUT/$f" "http://127.0.0.1:${LPORT}/pcaps/$f"
+  echo "  -> $OUT/$f"
+done